@@ -0,0 +1,349 @@
+package alfresco
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/acme"
+)
+
+// Key of the ACME account, relative to the workspace
+const acmeAccountKey = "config/acme/account.key"
+
+// Time allowed to the CA to validate the challenges and issue the certificate
+const acmeTimeout = 5 * time.Minute
+
+// ACMESettings holds the ACME account and names of the proxy certificate, stored in .env for renewals
+type ACMESettings struct {
+	Directory string
+	Email     string
+	Domains   []string
+	HTTPAddr  string // Address answering the HTTP-01 challenges
+}
+
+// Env returns the .env entries read by 'alf certs renew'
+func (a ACMESettings) Env() map[string]string {
+	return map[string]string{
+		"ACME_DIRECTORY": a.Directory,
+		"ACME_EMAIL":     a.Email,
+		"ACME_DOMAINS":   strings.Join(a.Domains, ","),
+		"ACME_HTTP_ADDR": a.HTTPAddr,
+	}
+}
+
+// loadACMESettings returns the settings stored in the .env file of dir by 'alf certs acme',
+// empty when the certificate was not obtained from an ACME CA
+func loadACMESettings(dir string) (ACMESettings, error) {
+	env, err := util.ReadEnvFile(filepath.Join(dir, ".env"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ACMESettings{}, err
+	}
+	if env["ACME_DIRECTORY"] == "" {
+		return ACMESettings{}, nil
+	}
+	return ACMESettings{
+		Directory: env["ACME_DIRECTORY"],
+		Email:     env["ACME_EMAIL"],
+		Domains:   strings.Split(env["ACME_DOMAINS"], ","),
+		HTTPAddr:  env["ACME_HTTP_ADDR"],
+	}, nil
+}
+
+var certsACMEOptions struct {
+	Directory string
+	Email     string
+	Domains   []string
+	HTTPAddr  string
+}
+
+var certsRenewOptions struct {
+	Force bool
+}
+
+var certsACMECmd = &cobra.Command{
+	Use:   "acme",
+	Short: "Obtain the proxy certificate from an ACME CA (Let's Encrypt by default)",
+	Long: `Obtain a certificate for the server name of the workspace from an ACME CA, validating
+the names with HTTP-01 challenges answered by alf-cli itself on --http-addr (":80" by default),
+which must be reachable from the CA through every name. The terms of service of the CA are
+accepted on your behalf.
+
+The certificate is installed as config/cert/server.crt and server.key, the account key is kept in
+config/acme/account.key and the settings in .env, so 'alf certs renew' can renew it.
+Use --directory to work with another CA, e.g. a local Pebble instance (trust its root with
+SSL_CERT_FILE=pebble.minica.pem).`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runCertsACME,
+}
+
+var certsRenewCmd = &cobra.Command{
+	Use:   "renew",
+	Short: "Renew the ACME certificate of the proxy when it is about to expire",
+	Long: `Renew the certificate obtained with 'alf certs acme' when it expires within 30 days, using
+the settings stored in .env, and reload nginx. Schedule it, e.g. with cron:
+
+  0 3 * * * alf certs renew --dir /opt/alfresco`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runCertsRenew,
+}
+
+func runCertsACME(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+	if !ws.HTTPS() {
+		return fmt.Errorf("this workspace was generated without --https")
+	}
+
+	settings := ACMESettings{
+		Directory: certsACMEOptions.Directory,
+		Email:     certsACMEOptions.Email,
+		Domains:   certsACMEOptions.Domains,
+		HTTPAddr:  certsACMEOptions.HTTPAddr,
+	}
+	if len(settings.Domains) == 0 {
+		settings.Domains = []string{ws.Env["SERVER_NAME"]}
+	}
+	for _, domain := range settings.Domains {
+		if net.ParseIP(domain) != nil || !strings.Contains(domain, ".") {
+			return fmt.Errorf("ACME certificates need public DNS names, not %q", domain)
+		}
+	}
+
+	if err := obtainACMECertificate(cmd.Context(), ws, settings); err != nil {
+		return err
+	}
+	if err := util.UpdateEnvFile(filepath.Join(ws.Dir, ".env"), settings.Env()); err != nil {
+		return fmt.Errorf("update .env: %w", err)
+	}
+	return reloadProxy(ws)
+}
+
+func runCertsRenew(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+	settings, err := loadACMESettings(ws.Dir)
+	if err != nil {
+		return err
+	}
+	if settings.Directory == "" {
+		return fmt.Errorf("the certificate of this workspace was not obtained with 'alf certs acme'")
+	}
+
+	if data, err := os.ReadFile(filepath.Join(ws.Dir, proxyCertDir, proxyCertFile)); err == nil && !certsRenewOptions.Force {
+		if certs, err := util.ParseCertificatesPEM(data); err == nil {
+			if left := time.Until(certs[0].NotAfter); left > certExpiryWarning {
+				fmt.Printf("The certificate is valid until %s: no renewal needed.\n", certs[0].NotAfter.Format(time.DateOnly))
+				return nil
+			}
+		}
+	}
+
+	if err := obtainACMECertificate(cmd.Context(), ws, settings); err != nil {
+		return err
+	}
+	return reloadProxy(ws)
+}
+
+// obtainACMECertificate orders a certificate for the domains, answers the HTTP-01 challenges
+// and installs the issued chain with a new key
+func obtainACMECertificate(ctx context.Context, ws *Workspace, settings ACMESettings) error {
+	ctx, cancel := context.WithTimeout(ctx, acmeTimeout)
+	defer cancel()
+
+	accountKey, err := loadACMEAccountKey(ws.Dir)
+	if err != nil {
+		return err
+	}
+	client := &acme.Client{Key: accountKey, DirectoryURL: settings.Directory, UserAgent: "alf-cli"}
+	account := &acme.Account{}
+	if settings.Email != "" {
+		account.Contact = []string{"mailto:" + settings.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("register ACME account at %s: %w", settings.Directory, err)
+	}
+
+	responder, err := listenHTTP01(settings.HTTPAddr)
+	if err != nil {
+		return err
+	}
+	defer responder.Close()
+
+	fmt.Printf("Ordering a certificate for %s...\n", strings.Join(settings.Domains, ", "))
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(settings.Domains...))
+	if err != nil {
+		return fmt.Errorf("create ACME order: %w", err)
+	}
+	for _, url := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, url)
+		if err != nil {
+			return err
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		var challenge *acme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == "http-01" {
+				challenge = c
+			}
+		}
+		if challenge == nil {
+			return fmt.Errorf("the CA offers no http-01 challenge for %s", authz.Identifier.Value)
+		}
+		response, err := client.HTTP01ChallengeResponse(challenge.Token)
+		if err != nil {
+			return err
+		}
+		responder.Set(client.HTTP01ChallengePath(challenge.Token), response)
+		if _, err := client.Accept(ctx, challenge); err != nil {
+			return fmt.Errorf("accept challenge for %s: %w", authz.Identifier.Value, err)
+		}
+		if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+			return fmt.Errorf("validate %s (is %s reachable from the CA?): %w", authz.Identifier.Value, settings.HTTPAddr, err)
+		}
+		fmt.Printf("Validated %s\n", authz.Identifier.Value)
+	}
+	orderURL := order.URI
+	if order, err = client.WaitOrder(ctx, orderURL); err != nil {
+		return fmt.Errorf("ACME order: %w", err)
+	}
+
+	key, err := util.NewKey()
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: settings.Domains[0]},
+		DNSNames: settings.Domains,
+	}, key)
+	if err != nil {
+		return err
+	}
+	der, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		// CAs may answer the finalization without the order location (e.g. Pebble), so the
+		// client cannot wait for the certificate by itself
+		order, waitErr := client.WaitOrder(ctx, orderURL)
+		if waitErr != nil || order.Status != acme.StatusValid {
+			return fmt.Errorf("issue certificate: %w", err)
+		}
+		if der, err = client.FetchCert(ctx, order.CertURL, true); err != nil {
+			return fmt.Errorf("download certificate: %w", err)
+		}
+	}
+	var chain []*x509.Certificate
+	for _, b := range der {
+		cert, err := x509.ParseCertificate(b)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, cert)
+	}
+
+	if err := installProxyCertificate(ws, chain, util.EncodeKeyPEM(key)); err != nil {
+		return err
+	}
+	fmt.Printf("Installed the certificate of %s, valid until %s\n", certificateName(chain[0]), chain[0].NotAfter.Format(time.DateOnly))
+	return nil
+}
+
+// loadACMEAccountKey reads the account key of the workspace, creating it on first use
+func loadACMEAccountKey(dir string) (crypto.Signer, error) {
+	path := filepath.Join(dir, acmeAccountKey)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return util.ParsePrivateKeyPEM(data)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	key, err := util.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, util.EncodeKeyPEM(key), 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// http01Responder serves the key authorizations of the pending HTTP-01 challenges
+type http01Responder struct {
+	server    *http.Server
+	mu        sync.Mutex
+	responses map[string]string
+}
+
+// listenHTTP01 starts answering HTTP-01 challenges on addr
+func listenHTTP01(addr string) (*http01Responder, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen for HTTP-01 challenges on %s: %w", addr, err)
+	}
+	r := &http01Responder{responses: make(map[string]string)}
+	r.server = &http.Server{Handler: r, ReadHeaderTimeout: 10 * time.Second}
+	go r.server.Serve(ln)
+	return r, nil
+}
+
+// Set publishes the response of the challenge at path
+func (r *http01Responder) Set(path, response string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses[path] = response
+}
+
+func (r *http01Responder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	response, ok := r.responses[req.URL.Path]
+	r.mu.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, response)
+}
+
+// Close stops answering challenges
+func (r *http01Responder) Close() error {
+	return r.server.Close()
+}
+
+func init() {
+	certsACMECmd.Flags().StringVar(&certsACMEOptions.Directory, "directory", acme.LetsEncryptURL, "ACME directory URL")
+	certsACMECmd.Flags().StringVar(&certsACMEOptions.Email, "email", "", "Contact email of the ACME account")
+	certsACMECmd.Flags().StringSliceVar(&certsACMEOptions.Domains, "domains", nil, "Names of the certificate (default: the server name)")
+	certsACMECmd.Flags().StringVar(&certsACMEOptions.HTTPAddr, "http-addr", ":80", "Address answering the HTTP-01 challenges")
+	certsRenewCmd.Flags().BoolVar(&certsRenewOptions.Force, "force", false, "Renew even if the certificate is not about to expire")
+	addWorkspaceFlag(certsACMECmd)
+	addWorkspaceFlag(certsRenewCmd)
+
+	certsCmd.AddCommand(certsACMECmd, certsRenewCmd)
+}
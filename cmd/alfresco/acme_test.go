@@ -0,0 +1,67 @@
+package alfresco
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestHTTP01Responder(t *testing.T) {
+	r := &http01Responder{responses: make(map[string]string)}
+	r.Set("/.well-known/acme-challenge/token", "token.thumbprint")
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/.well-known/acme-challenge/token", http.StatusOK, "token.thumbprint"},
+		{"/.well-known/acme-challenge/other", http.StatusNotFound, ""},
+		{"/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.path, w.Code, tt.status)
+		}
+		if tt.status == http.StatusOK && (w.Body.String() != tt.body || w.Header().Get("Content-Type") != "text/plain") {
+			t.Errorf("%s: got %q (%s), want %q", tt.path, w.Body.String(), w.Header().Get("Content-Type"), tt.body)
+		}
+	}
+}
+
+func TestCertsRenew(t *testing.T) {
+	// Nothing listens on the directory: reaching the CA fails
+	settings := ACMESettings{Directory: "http://127.0.0.1:1/directory", Email: "admin@example.org",
+		Domains: []string{"alfresco.example.org", "www.example.org"}, HTTPAddr: "127.0.0.1:0"}
+	renderWorkspace(t, &Configuration{HTTPS: true, Server: "alfresco.example.org", ACME: settings})
+
+	// Generating again keeps the settings in .env
+	loaded, err := loadACMESettings(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, settings) {
+		t.Fatalf("got settings %+v, want %+v", loaded, settings)
+	}
+
+	dir, force := workspaceDir, certsRenewOptions.Force
+	t.Cleanup(func() { workspaceDir, certsRenewOptions.Force = dir, force })
+	workspaceDir = "."
+	cmd := &cobra.Command{}
+	cmd.SetContext(t.Context())
+
+	// The installed certificate is valid for more than 30 days
+	certsRenewOptions.Force = false
+	if err := runCertsRenew(cmd, nil); err != nil {
+		t.Errorf("renewal attempted: %v", err)
+	}
+	certsRenewOptions.Force = true
+	if err := runCertsRenew(cmd, nil); err == nil {
+		t.Error("forced renewal did not contact the CA")
+	}
+}
@@ -0,0 +1,176 @@
+package alfresco
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+// Severity of an audit finding, from the most to the least severe
+const (
+	severityCritical = "critical"
+	severityHigh     = "high"
+	severityMedium   = "medium"
+	severityLow      = "low"
+)
+
+var auditSeverities = []string{severityCritical, severityHigh, severityMedium, severityLow}
+
+var auditSeverityStyles = map[string]lipgloss.Style{
+	severityCritical: doctorFailStyle.Bold(true),
+	severityHigh:     doctorFailStyle,
+	severityMedium:   probePendingStyle,
+	severityLow:      lipgloss.NewStyle().Faint(true),
+}
+
+// auditFinding is a risky setting found in a workspace
+type auditFinding struct {
+	Severity    string
+	Title       string
+	Remediation string
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Report the risky settings of a generated workspace",
+	Long: `Scan the files of a generated workspace for risky settings: default admin and ActiveMQ
+credentials, disabled CSRF protection, a world-readable .env, FTP or plain HTTP exposed beyond
+localhost and keystores shared by every installation.
+Every finding comes with a severity and a remediation hint. The command fails when a finding
+is high or critical.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runAudit,
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+
+	findings := auditWorkspace(ws)
+	if len(findings) == 0 {
+		fmt.Println(probeOKStyle.Render("✔") + " No risky settings found")
+		return nil
+	}
+	slices.SortStableFunc(findings, func(a, b auditFinding) int {
+		return slices.Index(auditSeverities, a.Severity) - slices.Index(auditSeverities, b.Severity)
+	})
+	serious := 0
+	for _, f := range findings {
+		fmt.Printf("  %s %s\n", auditSeverityStyles[f.Severity].Render(fmt.Sprintf("%-8s", strings.ToUpper(f.Severity))), f.Title)
+		fmt.Printf("           → %s\n", f.Remediation)
+		if f.Severity == severityCritical || f.Severity == severityHigh {
+			serious++
+		}
+	}
+	if serious > 0 {
+		return fmt.Errorf("%d high or critical finding(s)", serious)
+	}
+	return nil
+}
+
+// auditWorkspace returns the findings of every check
+func auditWorkspace(ws *Workspace) []auditFinding {
+	var findings []auditFinding
+	findings = append(findings, auditCredentials(ws)...)
+	findings = append(findings, auditEnvFile(ws)...)
+	findings = append(findings, auditExposure(ws)...)
+	findings = append(findings, auditKeystores(ws)...)
+	return findings
+}
+
+// auditCredentials reports the default or missing credentials of the repository and ActiveMQ
+func auditCredentials(ws *Workspace) []auditFinding {
+	var findings []auditFinding
+	if ws.Secret("ADMIN_PASSWORD") == util.ComputeHashPassword("admin") {
+		findings = append(findings, auditFinding{severityCritical,
+			"The repository admin user logs in with admin/admin",
+			"Run 'alf password reset --user admin' on the running stack"})
+	}
+	if strings.Contains(ws.Compose.Root.String("services", "alfresco", "environment", "JAVA_OPTS"), "-Dcsrf.filter.enabled=false") {
+		findings = append(findings, auditFinding{severityMedium,
+			"The CSRF filter of the repository is disabled (-Dcsrf.filter.enabled=false)",
+			"Remove -Dcsrf.filter.enabled=false from the JAVA_OPTS of alfresco in compose.yaml; the web apps reach the repository through the proxy, with the same origin"})
+	}
+
+	if ws.Compose.HasService("activemq") {
+		user, password := ws.Secret("ACTIVEMQ_ADMIN_USER"), ws.Secret("ACTIVEMQ_ADMIN_PASSWORD")
+		switch {
+		case user == "" || password == "":
+			findings = append(findings, auditFinding{severityHigh,
+				"ActiveMQ accepts connections without credentials",
+				"Set ACTIVEMQ_ADMIN_USER and ACTIVEMQ_ADMIN_PASSWORD in .env and recreate the services with 'alf up'"})
+		case user == "admin" && password == "admin":
+			findings = append(findings, auditFinding{severityMedium,
+				"ActiveMQ uses the default admin/admin credentials",
+				"Run 'alf secrets rotate amq'"})
+		}
+	}
+	return findings
+}
+
+// auditEnvFile reports a .env file readable by every user of the host
+func auditEnvFile(ws *Workspace) []auditFinding {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	info, err := os.Stat(filepath.Join(ws.Dir, ".env"))
+	if err != nil || info.Mode().Perm()&0o004 == 0 {
+		return nil
+	}
+	return []auditFinding{{severityHigh,
+		fmt.Sprintf(".env, holding passwords, is readable by every user of this host (%s)", info.Mode().Perm()),
+		fmt.Sprintf("Run 'chmod 600 %s'", filepath.Join(ws.Dir, ".env"))}}
+}
+
+// auditExposure reports plain-text protocols reachable beyond the host
+func auditExposure(ws *Workspace) []auditFinding {
+	var findings []auditFinding
+	if strings.Contains(ws.Compose.Root.String("services", "alfresco", "environment", "JAVA_OPTS"), "-Dftp.enabled=true") {
+		if ip := ws.Env["BIND_IP_FTP"]; ip == "" || ip == "0.0.0.0" || ip == "::" {
+			findings = append(findings, auditFinding{severityMedium,
+				"FTP, sending passwords in clear text, listens on every interface (0.0.0.0)",
+				"Set BIND_IP_FTP in .env to 127.0.0.1 or a private address, or generate the workspace again without --ftp"})
+		}
+	}
+
+	server := ws.Env["SERVER_NAME"]
+	if !ws.HTTPS() && server != "localhost" && server != "127.0.0.1" && server != "::1" {
+		findings = append(findings, auditFinding{severityHigh,
+			fmt.Sprintf("The proxy serves %s over plain HTTP: credentials and tickets travel in clear text", server),
+			"Generate the workspace again with --https, then install a trusted certificate with 'alf certs acme' or 'alf certs import'"})
+	}
+	return findings
+}
+
+// auditKeystores reports the keystores shipped with alf-cli or the repository image,
+// whose keys are public
+func auditKeystores(ws *Workspace) []auditFinding {
+	var findings []auditFinding
+	if ws.SolrComm() == "https" && ws.Env["SSL_KEYSTORE_TYPE"] != "PKCS12" {
+		findings = append(findings, auditFinding{severityHigh,
+			"The mTLS keystores between the repository and Solr are the ones shared by every alf-cli installation",
+			"Generate the workspace again: new workspaces get their own CA and keystores, which 'alf secrets rotate solr' can renew"})
+	}
+	if ws.Env["METADATA_KEYSTORE_TYPE"] != "PKCS12" {
+		findings = append(findings, auditFinding{severityMedium,
+			"Encrypted metadata properties use the key of the default keystore, shared by every installation",
+			"Generate the workspace again before loading content: new workspaces get their own metadata key"})
+	}
+	return findings
+}
+
+func init() {
+	addWorkspaceFlag(auditCmd)
+
+	rootCmd.AddCommand(auditCmd)
+}
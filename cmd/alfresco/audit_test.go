@@ -0,0 +1,33 @@
+package alfresco
+
+import (
+	"testing"
+
+	"github.com/aborroy/alf-cli/internal/util"
+)
+
+func TestAuditGeneratedWorkspace(t *testing.T) {
+	admin := util.ComputeHashPassword("s3cret")
+	tests := []struct {
+		name string
+		cfg  Configuration
+	}{
+		{"default", Configuration{AdminPassword: admin}},
+		{"hardened with secrets", Configuration{AdminPassword: admin, Hardened: true, DockerSecrets: true, HTTPS: true,
+			Server: "alfresco.example.org", SolrComm: "https", UseActiveMQ: true, AmqUser: "alfresco", AmqPassword: "s3cret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renderWorkspace(t, &tt.cfg)
+			ws, err := openWorkspace(".")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range auditWorkspace(ws) {
+				if f.Severity == severityCritical || f.Severity == severityHigh {
+					t.Errorf("%s finding: %s", f.Severity, f.Title)
+				}
+			}
+		})
+	}
+}
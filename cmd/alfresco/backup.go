@@ -0,0 +1,161 @@
+package alfresco
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/spf13/cobra"
+)
+
+// Name of the manifest file stored at the root of every backup archive
+const backupManifestFile = "manifest.json"
+
+// BackupManifest describes the content of a backup archive
+type BackupManifest struct {
+	Created     time.Time    `json:"created"`
+	Version     string       `json:"version"`
+	RepoTag     string       `json:"repo_tag"`
+	Database    string       `json:"database"`
+	VolumeMode  string       `json:"volume_mode"` // "docker" or "bind"
+	ProjectName string       `json:"project_name"`
+	Items       []BackupItem `json:"items"`
+}
+
+// BackupItem is a single file captured in a backup archive
+type BackupItem struct {
+	Kind   string `json:"kind"`   // "database", "content" or "index"
+	Name   string `json:"name"`   // Volume or database name
+	File   string `json:"file"`   // Path inside the archive
+	Source string `json:"source"` // Docker volume, bind mount or service it was taken from
+}
+
+var backupOptions struct {
+	Output       string
+	IncludeIndex bool
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up the database and content store of a generated workspace",
+	RunE:  runBackup,
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+
+	archive, err := createBackup(ws, backupOptions.Output, "alf-backup", backupOptions.IncludeIndex)
+	if err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	fmt.Printf("Backup written to %s\n", archive)
+	return nil
+}
+
+// createBackup dumps the database, archives the content store (and optionally the
+// Solr indexes) and packs everything with a manifest in a timestamped tarball.
+func createBackup(ws *Workspace, outputDir, prefix string, includeIndex bool) (string, error) {
+	if !filepath.IsAbs(outputDir) {
+		outputDir = filepath.Join(ws.Dir, outputDir)
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return "", fmt.Errorf("mkdir %s: %w", outputDir, err)
+	}
+
+	staging, err := os.MkdirTemp(ws.Dir, ".alf-backup-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest := BackupManifest{
+		Created:     time.Now().UTC(),
+		Version:     ws.Version(),
+		RepoTag:     ws.Env["REPO_TAG"],
+		Database:    ws.Database(),
+		VolumeMode:  "bind",
+		ProjectName: ws.ProjectName(),
+	}
+	if ws.UseDockerVolume() {
+		manifest.VolumeMode = "docker"
+	}
+
+	// Database first: the content store is append-only, so a dump followed by
+	// a content copy never references missing files.
+	fmt.Printf("Dumping %s database...\n", manifest.Database)
+	if err := dumpDatabase(ws, filepath.Join(staging, "database.sql")); err != nil {
+		return "", err
+	}
+	manifest.Items = append(manifest.Items, BackupItem{Kind: "database", Name: ws.DbName(), File: "database.sql", Source: manifest.Database})
+
+	volumes := []BackupItem{{Kind: "content", Name: "alf-repo-data"}}
+	if slices.Contains(ws.DataVolumes(), "minio-data") {
+		// Content of the S3 content store, referenced by the database dump
+		volumes = append(volumes, BackupItem{Kind: "content", Name: "minio-data"})
+	}
+	if includeIndex {
+		for _, v := range ws.SolrVolumes() {
+			volumes = append(volumes, BackupItem{Kind: "index", Name: v})
+		}
+	}
+	for _, item := range volumes {
+		item.File = item.Name + ".tar"
+		item.Source = ws.VolumeSource(item.Name)
+		fmt.Printf("Archiving %s...\n", item.Name)
+		if err := ws.runHelper(
+			[]string{item.Source + ":/source:ro", staging + ":/backup"},
+			fmt.Sprintf("tar -cf /backup/%s -C /source .", item.File),
+		); err != nil {
+			return "", fmt.Errorf("archive %s: %w", item.Name, err)
+		}
+		manifest.Items = append(manifest.Items, item)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(staging, backupManifestFile), data, 0o644); err != nil {
+		return "", err
+	}
+
+	archive := filepath.Join(outputDir, fmt.Sprintf("%s-%s.tar.gz", prefix, manifest.Created.Format("20060102-150405")))
+	if err := util.ArchiveDir(staging, archive); err != nil {
+		return "", err
+	}
+	return archive, nil
+}
+
+// dumpDatabase writes a plain SQL dump of the repository database, taken inside the DB container
+func dumpDatabase(ws *Workspace, outPath string) error {
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	cmd := ws.compose("exec", "-T", "postgres", "pg_dump", "-U", ws.DbUser(), "-d", ws.DbName(), "--clean", "--if-exists")
+	if ws.Database() == "mariadb" {
+		cmd = ws.compose("exec", "-T", "mariadb", "sh", "-c", `mariadb-dump -u"$MYSQL_USER" -p`+mariadbPassword+` "$MYSQL_DATABASE"`)
+	}
+	cmd.Stdout = out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("dump %s database (is the stack running?): %w", ws.Database(), err)
+	}
+	return nil
+}
+
+func init() {
+	addWorkspaceFlag(backupCmd)
+	backupCmd.Flags().StringVarP(&backupOptions.Output, "output", "o", "backups", "Folder where the backup archive is written")
+	backupCmd.Flags().BoolVar(&backupOptions.IncludeIndex, "include-index", false, "Include the Solr indexes")
+
+	rootCmd.AddCommand(backupCmd)
+}
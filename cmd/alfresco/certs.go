@@ -0,0 +1,459 @@
+package alfresco
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/spf13/cobra"
+)
+
+// Validity of the local CA and of the proxy certificate (browsers reject server certificates above 398 days)
+const (
+	proxyCAValidity   = 10 * 365 * 24 * time.Hour
+	proxyCertValidity = 397 * 24 * time.Hour
+)
+
+// Files of the proxy certificate, relative to the workspace
+const (
+	proxyCertDir  = "config/cert"
+	proxyCAFile   = "ca.crt"
+	proxyCAKey    = "ca.key"
+	proxyCertFile = "server.crt"
+	proxyKeyFile  = "server.key"
+)
+
+// proxyCertNames returns the names the proxy certificate is valid for: the server name,
+// the HTTP binding IP and the extra hostnames.
+func proxyCertNames(cfg *Configuration) []string {
+	candidates := []string{cfg.Server}
+	if ip := net.ParseIP(cfg.BindingIP); ip != nil && !ip.IsUnspecified() {
+		candidates = append(candidates, cfg.BindingIP)
+	}
+	var names []string
+	for _, name := range append(candidates, cfg.CertHostnames...) {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// writeProxyCertificate writes under config/cert a local CA for the workspace and a server
+// certificate signed by it for the names. Generating again keeps the CA, which browsers may
+// already trust, and the server certificate while it covers the same names and does not
+// expire soon. A certificate installed with 'alf certs import' or 'alf certs acme', not
+// issued by the local CA, is left alone. Private keys are only readable by the owner; ca.crt
+// is the file to trust in browsers.
+func writeProxyCertificate(dir, project string, names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("no hostname for the proxy certificate")
+	}
+	certDir := filepath.Join(dir, proxyCertDir)
+	if err := os.MkdirAll(certDir, 0o755); err != nil {
+		return err
+	}
+
+	ca := loadProxyCA(certDir)
+	if leaf := installedProxyCertificate(certDir); leaf != nil && (ca == nil || !issuedBy(leaf, ca.Cert)) {
+		fmt.Printf("Keeping %s of %s, not issued by the local CA\n", filepath.Join(proxyCertDir, proxyCertFile), certificateName(leaf))
+		if err := leaf.VerifyHostname(names[0]); err != nil {
+			fmt.Printf("\x1b[33;1mWARNING: %v: install a certificate for it with 'alf certs import'\x1b[0m\n", err)
+		}
+		return nil
+	}
+	if ca == nil {
+		caName := "alf-cli local CA"
+		if project != "" {
+			caName += " (" + project + ")"
+		}
+		var err error
+		if ca, err = util.NewCA(caName, proxyCAValidity); err != nil {
+			return err
+		}
+		if err := writeCertKey(certDir, proxyCAFile, proxyCAKey, ca); err != nil {
+			return err
+		}
+	} else if proxyCertificateCurrent(certDir, ca, names) {
+		return nil
+	}
+
+	req := util.CertRequest{CommonName: names[0], Server: true, Validity: proxyCertValidity}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			req.IPAddresses = append(req.IPAddresses, ip)
+		} else {
+			req.DNSNames = append(req.DNSNames, name)
+		}
+	}
+	server, err := ca.Issue(req)
+	if err != nil {
+		return err
+	}
+	return writeCertKey(certDir, proxyCertFile, proxyKeyFile, server)
+}
+
+// writeCertKey writes a certificate and its private key, only readable by the owner
+func writeCertKey(certDir, certFile, keyFile string, ck *util.CertKey) error {
+	if err := os.WriteFile(filepath.Join(certDir, certFile), ck.CertPEM(), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", certFile, err)
+	}
+	if err := os.WriteFile(filepath.Join(certDir, keyFile), ck.KeyPEM(), 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", keyFile, err)
+	}
+	return nil
+}
+
+// loadProxyCA returns the local CA generated before in certDir, or nil when there is none
+func loadProxyCA(certDir string) *util.CertKey {
+	certPEM, err := os.ReadFile(filepath.Join(certDir, proxyCAFile))
+	if err != nil {
+		return nil
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(certDir, proxyCAKey))
+	if err != nil {
+		return nil
+	}
+	certs, err := util.ParseCertificatesPEM(certPEM)
+	if err != nil || !certs[0].IsCA {
+		return nil
+	}
+	key, err := util.ParsePrivateKeyPEM(keyPEM)
+	if err != nil || !util.MatchesKey(certs[0], key) {
+		return nil
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil
+	}
+	return &util.CertKey{Cert: certs[0], Key: rsaKey}
+}
+
+// installedProxyCertificate returns the first certificate of server.crt, or nil when there
+// is none
+func installedProxyCertificate(certDir string) *x509.Certificate {
+	data, err := os.ReadFile(filepath.Join(certDir, proxyCertFile))
+	if err != nil {
+		return nil
+	}
+	chain, err := util.ParseCertificatesPEM(data)
+	if err != nil {
+		return nil
+	}
+	return chain[0]
+}
+
+// proxyCertificateCurrent reports whether server.crt is issued by the CA for exactly the
+// names, matches server.key and stays valid beyond the expiry warning period
+func proxyCertificateCurrent(certDir string, ca *util.CertKey, names []string) bool {
+	leaf := installedProxyCertificate(certDir)
+	if leaf == nil {
+		return false
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(certDir, proxyKeyFile))
+	if err != nil {
+		return false
+	}
+	key, err := util.ParsePrivateKeyPEM(keyPEM)
+	if err != nil || !util.MatchesKey(leaf, key) {
+		return false
+	}
+	if !issuedBy(leaf, ca.Cert) || time.Until(leaf.NotAfter) < certExpiryWarning {
+		return false
+	}
+
+	var current []string
+	current = append(current, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		current = append(current, ip.String())
+	}
+	var wanted []string
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			name = ip.String()
+		}
+		wanted = append(wanted, name)
+	}
+	slices.Sort(current)
+	slices.Sort(wanted)
+	return slices.Equal(current, wanted)
+}
+
+// issuedBy reports whether a certificate is signed by the CA, whatever its validity period
+func issuedBy(cert, ca *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, ca.RawSubject) && cert.CheckSignatureFrom(ca) == nil
+}
+
+// Installed certificates expiring within this period are reported
+const certExpiryWarning = 30 * 24 * time.Hour
+
+var certsImportOptions struct {
+	Cert string
+	Key  string
+	CA   string
+}
+
+var certsCmd = &cobra.Command{
+	Use:   "certs",
+	Short: "Manage the HTTPS certificate of the proxy",
+}
+
+var certsImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Install your own certificate and key for the HTTPS proxy",
+	Long: `Validate a PEM certificate (server certificate first, followed by its intermediates)
+and its private key, and install them as config/cert/server.crt and server.key.
+The certificate must match the key and cover the server name of the workspace. With --ca
+the chain is verified against that CA, which replaces config/cert/ca.crt.
+nginx is reloaded when the proxy is running.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runCertsImport,
+}
+
+var certsInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the subject, names and expiry of the installed certificates",
+	Args:  cobra.NoArgs,
+	RunE:  runCertsInfo,
+}
+
+func runCertsImport(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+	if !ws.HTTPS() {
+		return fmt.Errorf("this workspace was generated without --https")
+	}
+
+	certPEM, err := os.ReadFile(certsImportOptions.Cert)
+	if err != nil {
+		return err
+	}
+	chain, err := util.ParseCertificatesPEM(certPEM)
+	if err != nil {
+		return fmt.Errorf("%s: %w", certsImportOptions.Cert, err)
+	}
+	keyPEM, err := os.ReadFile(certsImportOptions.Key)
+	if err != nil {
+		return err
+	}
+	key, err := util.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return fmt.Errorf("%s: %w", certsImportOptions.Key, err)
+	}
+
+	leaf := chain[0]
+	server := ws.Env["SERVER_NAME"]
+	if !util.MatchesKey(leaf, key) {
+		return fmt.Errorf("the certificate does not match the private key")
+	}
+	if err := leaf.VerifyHostname(server); err != nil {
+		return fmt.Errorf("the certificate does not cover the server name: %w", err)
+	}
+	now := time.Now()
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("the certificate expired on %s", leaf.NotAfter.Format(time.DateOnly))
+	}
+	if now.Before(leaf.NotBefore) {
+		return fmt.Errorf("the certificate is not valid before %s", leaf.NotBefore.Format(time.DateOnly))
+	}
+
+	var caPEM []byte
+	if certsImportOptions.CA != "" {
+		if caPEM, err = os.ReadFile(certsImportOptions.CA); err != nil {
+			return err
+		}
+		cas, err := util.ParseCertificatesPEM(caPEM)
+		if err != nil {
+			return fmt.Errorf("%s: %w", certsImportOptions.CA, err)
+		}
+		opts := x509.VerifyOptions{
+			DNSName:       server,
+			Roots:         x509.NewCertPool(),
+			Intermediates: x509.NewCertPool(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		for _, ca := range cas {
+			opts.Roots.AddCert(ca)
+		}
+		for _, cert := range chain[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		if _, err := leaf.Verify(opts); err != nil {
+			return fmt.Errorf("the certificate chain is not valid for %s: %w", certsImportOptions.CA, err)
+		}
+	} else if len(leaf.ExtKeyUsage) > 0 && !slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageServerAuth) &&
+		!slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageAny) {
+		return fmt.Errorf("the certificate cannot be used by a server (no serverAuth extended key usage)")
+	}
+	if left := leaf.NotAfter.Sub(now); left < certExpiryWarning {
+		fmt.Printf("\x1b[33;1mWARNING: The certificate expires in %d days (%s)\x1b[0m\n", int(left.Hours()/24), leaf.NotAfter.Format(time.DateOnly))
+	}
+
+	if err := installProxyCertificate(ws, chain, keyPEM); err != nil {
+		return err
+	}
+	if caPEM != nil {
+		certDir := filepath.Join(ws.Dir, proxyCertDir)
+		if err := os.WriteFile(filepath.Join(certDir, proxyCAFile), caPEM, 0o644); err != nil {
+			return err
+		}
+		// The key of the generated CA does not belong to the new one
+		if err := os.Remove(filepath.Join(certDir, proxyCAKey)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	fmt.Printf("Installed the certificate of %s, valid until %s\n", certificateName(leaf), leaf.NotAfter.Format(time.DateOnly))
+
+	return reloadProxy(ws)
+}
+
+// installProxyCertificate writes the chain and key read by nginx. nginx expects the server
+// certificate followed by its intermediates.
+func installProxyCertificate(ws *Workspace, chain []*x509.Certificate, keyPEM []byte) error {
+	var fullchain []byte
+	for _, cert := range chain {
+		fullchain = append(fullchain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	certDir := filepath.Join(ws.Dir, proxyCertDir)
+	if err := os.MkdirAll(certDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(certDir, proxyCertFile), fullchain, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(certDir, proxyKeyFile), keyPEM, 0o600)
+}
+
+// certificateName returns the common name of a certificate, or its first DNS name
+func certificateName(cert *x509.Certificate) string {
+	if cert.Subject.CommonName == "" && len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return cert.Subject.CommonName
+}
+
+// reloadProxy makes a running nginx read the certificate files again
+func reloadProxy(ws *Workspace) error {
+	running, err := ws.RunningServices()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(running, "proxy") {
+		fmt.Println("The proxy is not running: the certificate is used on the next 'alf up'.")
+		return nil
+	}
+	if err := ws.compose("exec", "-T", "proxy", "nginx", "-s", "reload").Run(); err != nil {
+		return fmt.Errorf("reload nginx: %w", err)
+	}
+	fmt.Println("nginx reloaded")
+	return nil
+}
+
+func runCertsInfo(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+	if !ws.HTTPS() {
+		return fmt.Errorf("this workspace was generated without --https")
+	}
+
+	certDir := filepath.Join(ws.Dir, proxyCertDir)
+	var key crypto.Signer
+	if data, err := os.ReadFile(filepath.Join(certDir, proxyKeyFile)); err == nil {
+		key, _ = util.ParsePrivateKeyPEM(data)
+	}
+
+	for _, file := range []string{proxyCertFile, proxyCAFile} {
+		data, err := os.ReadFile(filepath.Join(certDir, file))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		certs, err := util.ParseCertificatesPEM(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		for i, cert := range certs {
+			fmt.Printf("%s", filepath.Join(proxyCertDir, file))
+			if len(certs) > 1 {
+				fmt.Printf(" [%d]", i)
+			}
+			fmt.Println()
+			printCertificate(cert)
+			if file == proxyCertFile && i == 0 {
+				fmt.Printf("  Key:      %s\n", keyStatus(cert, key))
+				if err := cert.VerifyHostname(ws.Env["SERVER_NAME"]); err != nil {
+					fmt.Printf("\x1b[33;1m  WARNING: %v\x1b[0m\n", err)
+				}
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// printCertificate writes the main fields of a certificate, flagging the expired or expiring ones
+func printCertificate(cert *x509.Certificate) {
+	fmt.Printf("  Subject:  %s\n", cert.Subject)
+	fmt.Printf("  Issuer:   %s\n", cert.Issuer)
+	var names []string
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) > 0 {
+		fmt.Printf("  Names:    %s\n", strings.Join(names, ", "))
+	}
+	left := time.Until(cert.NotAfter)
+	expiry := fmt.Sprintf("%s (%d days left)", cert.NotAfter.Format(time.DateOnly), int(left.Hours()/24))
+	switch {
+	case left <= 0:
+		expiry = fmt.Sprintf("\x1b[31;1m%s (expired)\x1b[0m", cert.NotAfter.Format(time.DateOnly))
+	case left < certExpiryWarning:
+		expiry = "\x1b[33;1m" + expiry + "\x1b[0m"
+	}
+	fmt.Printf("  Expires:  %s\n", expiry)
+}
+
+// keyStatus describes whether server.key belongs to the server certificate
+func keyStatus(cert *x509.Certificate, key crypto.Signer) string {
+	switch {
+	case key == nil:
+		return "missing or unreadable " + proxyKeyFile
+	case util.MatchesKey(cert, key):
+		return "matches " + proxyKeyFile
+	default:
+		return "\x1b[31;1mdoes not match " + proxyKeyFile + "\x1b[0m"
+	}
+}
+
+func init() {
+	certsImportCmd.Flags().StringVar(&certsImportOptions.Cert, "cert", "", "PEM certificate, followed by its intermediates (fullchain)")
+	certsImportCmd.Flags().StringVar(&certsImportOptions.Key, "key", "", "PEM private key of the certificate")
+	certsImportCmd.Flags().StringVar(&certsImportOptions.CA, "ca", "", "PEM CA certificate the chain must verify against")
+	certsImportCmd.MarkFlagRequired("cert")
+	certsImportCmd.MarkFlagRequired("key")
+	addWorkspaceFlag(certsImportCmd)
+	addWorkspaceFlag(certsInfoCmd)
+
+	certsCmd.AddCommand(certsImportCmd, certsInfoCmd)
+	rootCmd.AddCommand(certsCmd)
+}
@@ -0,0 +1,158 @@
+package alfresco
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/aborroy/alf-cli/internal/util"
+)
+
+// readCertificate returns the first certificate of a PEM file
+func readCertificate(t *testing.T, path string) *x509.Certificate {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := util.ParseCertificatesPEM(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certs[0]
+}
+
+// verifyProxyCertificate checks that server.crt is trusted through ca.crt for every name
+func verifyProxyCertificate(t *testing.T, certDir string, names []string) {
+	t.Helper()
+	roots := x509.NewCertPool()
+	roots.AddCert(readCertificate(t, filepath.Join(certDir, proxyCAFile)))
+	leaf := readCertificate(t, filepath.Join(certDir, proxyCertFile))
+	for _, name := range names {
+		opts := x509.VerifyOptions{DNSName: name, Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+		if _, err := leaf.Verify(opts); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestProxyCertificateNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Configuration
+		names []string
+	}{
+		{"any address", Configuration{Server: "alfresco.example.org", BindingIP: "0.0.0.0", CertHostnames: []string{"alf.local", "10.0.0.5"}},
+			[]string{"alfresco.example.org", "alf.local", "10.0.0.5"}},
+		{"binding IP", Configuration{Server: "localhost", BindingIP: "192.168.1.10", CertHostnames: []string{"localhost"}},
+			[]string{"localhost", "192.168.1.10"}},
+	}
+	for _, tt := range tests {
+		names := proxyCertNames(&tt.cfg)
+		if !slices.Equal(names, tt.names) {
+			t.Errorf("%s: got names %v, want %v", tt.name, names, tt.names)
+		}
+		dir := t.TempDir()
+		if err := writeProxyCertificate(dir, "test", names); err != nil {
+			t.Fatal(err)
+		}
+		certDir := filepath.Join(dir, proxyCertDir)
+		verifyProxyCertificate(t, certDir, names)
+
+		leaf := readCertificate(t, filepath.Join(certDir, proxyCertFile))
+		for _, ip := range leaf.IPAddresses {
+			if ip.IsUnspecified() {
+				t.Errorf("%s: certificate issued for %s", tt.name, ip)
+			}
+		}
+		if !slices.Equal(leaf.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}) {
+			t.Errorf("%s: extended key usage %v, want serverAuth", tt.name, leaf.ExtKeyUsage)
+		}
+	}
+}
+
+func TestProxyCertificateKeptOnRegeneration(t *testing.T) {
+	dir := t.TempDir()
+	certDir := filepath.Join(dir, proxyCertDir)
+	read := func(file string) []byte {
+		data, err := os.ReadFile(filepath.Join(certDir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	names := []string{"localhost", "127.0.0.1"}
+	if err := writeProxyCertificate(dir, "test", names); err != nil {
+		t.Fatal(err)
+	}
+	ca, server := read(proxyCAFile), read(proxyCertFile)
+
+	// Same names, in another order: nothing is issued
+	if err := writeProxyCertificate(dir, "test", []string{"127.0.0.1", "localhost"}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read(proxyCAFile), ca) || !bytes.Equal(read(proxyCertFile), server) {
+		t.Error("certificates issued again for the same names")
+	}
+
+	// A new name only reissues the server certificate
+	names = append(names, "alf.local")
+	if err := writeProxyCertificate(dir, "test", names); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read(proxyCAFile), ca) {
+		t.Error("CA replaced")
+	}
+	if bytes.Equal(read(proxyCertFile), server) {
+		t.Error("server certificate not issued for the new name")
+	}
+	verifyProxyCertificate(t, certDir, names)
+}
+
+func TestImportedCertificateKept(t *testing.T) {
+	other, err := util.NewCA("Other CA", proxyCAValidity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := other.Issue(util.CertRequest{CommonName: "localhost", DNSNames: []string{"localhost"}, Server: true, Validity: proxyCertValidity})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "alf certs import" with and without --ca, which replaces ca.crt and removes ca.key
+	for _, withCA := range []bool{false, true} {
+		dir := t.TempDir()
+		certDir := filepath.Join(dir, proxyCertDir)
+		if err := writeProxyCertificate(dir, "test", []string{"localhost"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeCertKey(certDir, proxyCertFile, proxyKeyFile, imported); err != nil {
+			t.Fatal(err)
+		}
+		if withCA {
+			if err := os.WriteFile(filepath.Join(certDir, proxyCAFile), other.CertPEM(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(filepath.Join(certDir, proxyCAKey)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		before := make(map[string][]byte)
+		for _, file := range []string{proxyCAFile, proxyCAKey, proxyCertFile, proxyKeyFile} {
+			before[file], _ = os.ReadFile(filepath.Join(certDir, file))
+		}
+
+		if err := writeProxyCertificate(dir, "test", []string{"localhost", "alf.local"}); err != nil {
+			t.Fatal(err)
+		}
+		for file, data := range before {
+			if after, _ := os.ReadFile(filepath.Join(certDir, file)); !bytes.Equal(after, data) {
+				t.Errorf("--ca %v: %s changed", withCA, file)
+			}
+		}
+	}
+}
@@ -1,14 +1,19 @@
 package alfresco
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/aborroy/alf-cli/internal/util"
 	"github.com/aborroy/alf-cli/ui/selector"
@@ -19,32 +24,153 @@ import (
 // Configuration holds all the Docker Compose configuration options
 type Configuration struct {
 	Version          string
+	ProjectName      string
 	RAM              int64 // RAM in GB
 	CPUs             int64
 	HTTPS            bool
+	CertHostnames    []string // Extra names of the proxy certificate
 	Server           string
 	AdminPassword    string
 	Database         string
+	DbUser           string
+	DbName           string
 	DbPassword       string
+	DbPasswordFile   string // File with the DB password, "-" for stdin
 	Port             string
+	PortOffset       int
 	UseBinding       bool
 	BindingIP        string
 	UseFtp           bool
 	FtpBindingIP     string
+	FtpPort          int
+	FtpDataPortFrom  int
+	FtpDataPortTo    int
 	IndexCrossLocale bool
 	IndexContent     bool
 	SolrComm         string
 	Secret           string
+	SSLStores        SSLStores // mTLS keystores, with SolrComm https
+	MetadataKeystore MetadataKeystore
+	ACME             ACMESettings // Certificate obtained with 'alf certs acme', kept when generating again
+	SolrShards       int
+	SolrShardMethod  string
+	TransformMode    string
+	ContentStore     string
+	S3ConnectorAmp   string
+	S3AccessKey      string
+	S3SecretKey      string
+	Monitoring       bool
+	GrafanaPassword  string
 	UseActiveMQ      bool
 	AmqUser          string
 	AmqPassword      string
 	Addons           []string
 	UseDockerVolume  bool
+	DockerSecrets    bool // Credentials as files under secrets/ instead of .env
+	Hardened         bool // Least-privilege containers, internal networks and nginx security headers
 	Resources        map[string]util.Resource
+	ServiceNetworks  map[string][]string // Networks of each service, derived from depends_on
 }
 
 var flags Configuration
 
+// Default FTP control port and passive data port range
+var defaultFtpPorts = []int{2121, 2433, 2434}
+
+// SolrInstance describes one Search Services container in the generated stack
+type SolrInstance struct {
+	Name   string // Compose service name, also used as Solr hostname
+	Volume string // Index volume name
+	Shard  int    // Shard instance number
+}
+
+// SolrInstances returns a Search Services container for every configured shard.
+// The first instance keeps the "solr6" name used by the non-sharded topology.
+func (c *Configuration) SolrInstances() []SolrInstance {
+	shards := max(c.SolrShards, 1)
+	instances := make([]SolrInstance, shards)
+	for i := range instances {
+		instances[i] = SolrInstance{Name: "solr6", Volume: "solr-data", Shard: i}
+		if i > 0 {
+			instances[i].Name = fmt.Sprintf("solr6-%d", i)
+			instances[i].Volume = fmt.Sprintf("solr-data-%d", i)
+		}
+	}
+	return instances
+}
+
+// NamedVolumes returns the Docker volumes declared when UseDockerVolume is set
+func (c *Configuration) NamedVolumes() []string {
+	var volumes []string
+	if c.UseActiveMQ {
+		volumes = append(volumes, "activemq-data")
+	}
+	if c.ContentStore == "s3" {
+		volumes = append(volumes, "minio-data")
+	}
+	if c.Monitoring {
+		volumes = append(volumes, "prometheus-data")
+	}
+	volumes = append(volumes, c.Database+"-data", "alf-repo-data")
+	for _, solr := range c.SolrInstances() {
+		volumes = append(volumes, solr.Volume)
+	}
+	return volumes
+}
+
+// TransformEngine describes an individual T-Engine deployed in "split" transform mode
+type TransformEngine struct {
+	Name  string // Compose service and resource entry name
+	Image string // Docker image, tagged with TRANSFORM_TAG
+	Key   string // localTransform.<key>.url repository property
+	Jar   string // Application started by the image, under /usr/bin
+	User  string // uid the image runs as
+}
+
+// Individual T-Engines replacing transform-core-aio
+var transformEngines = []TransformEngine{
+	{Name: "transform-imagemagick", Image: "docker.io/alfresco/alfresco-imagemagick", Key: "imagemagick", Jar: "alfresco-transform-imagemagick.jar", User: "33002"},
+	{Name: "transform-libreoffice", Image: "docker.io/alfresco/alfresco-libreoffice", Key: "libreoffice", Jar: "alfresco-transform-libreoffice.jar", User: "33003"},
+	{Name: "transform-pdfrenderer", Image: "docker.io/alfresco/alfresco-pdf-renderer", Key: "pdfrenderer", Jar: "alfresco-transform-pdf-renderer.jar", User: "33001"},
+	{Name: "transform-tika", Image: "docker.io/alfresco/alfresco-tika", Key: "tika", Jar: "alfresco-transform-tika.jar", User: "33004"},
+	{Name: "transform-misc", Image: "docker.io/alfresco/alfresco-transform-misc", Key: "misc", Jar: "alfresco-transform-misc.jar", User: "33006"},
+}
+
+// TransformEngines returns the T-Engines deployed for the configured transform mode
+func (c *Configuration) TransformEngines() []TransformEngine {
+	if c.TransformMode == "split" {
+		return transformEngines
+	}
+	return []TransformEngine{{Name: "transform-core-aio", Image: "docker.io/alfresco/alfresco-transform-core-aio", Key: "core-aio",
+		Jar: "alfresco-transform-core-aio.jar", User: "33017"}}
+}
+
+// TransformSecrets reports whether the T-Engines read the ActiveMQ credentials from Docker
+// secrets, through secrets-entrypoint.sh mounted from the transform folder
+func (c *Configuration) TransformSecrets() bool {
+	return c.DockerSecrets && c.UseActiveMQ && (c.AmqUser != "" || c.AmqPassword != "")
+}
+
+// scaledServices returns the util.Scale entries budgeted for the configured stack. ActiveMQ
+// is budgeted even when not deployed, so the all-in-one stacks keep the allocation they had
+// before the budget followed the deployed services.
+func scaledServices(config *Configuration) []string {
+	services := []string{"database", "activemq", "alfresco", "solr6", "share", "content-app", "control-center", "proxy"}
+	for _, engine := range config.TransformEngines() {
+		services = append(services, engine.Name)
+	}
+	if slices.Contains(config.Addons, "alf-tengine-ocr") {
+		services = append(services, "transform-ocr")
+	}
+	if config.ContentStore == "s3" {
+		services = append(services, "minio")
+	}
+	if config.Monitoring {
+		services = append(services, "prometheus", "grafana", "database-exporter")
+	}
+	return services
+}
+
 // Available addon options
 var availableAddons = []selector.Option{
 	{Code: "ootbee-support-tools", Description: "Order of the Bee Support Tools 1.2.2.0"},
@@ -72,9 +198,35 @@ func runDockerCompose(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to generate config file: %w", err)
 	}
 
+	if err := registerStack(config); err != nil {
+		fmt.Printf("\x1b[33;1mWARNING: stack not added to the 'alf list' registry: %v\x1b[0m\n", err)
+	}
+
 	return nil
 }
 
+// registerStack records the generated workspace in the registry read by "alf list"
+func registerStack(config *Configuration) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	name := config.ProjectName
+	if name == "" {
+		name = defaultProjectName(dir)
+	}
+	return util.RegisterStack(util.StackEntry{
+		Name:     name,
+		Dir:      dir,
+		Version:  config.Version,
+		Database: config.Database,
+		Created:  time.Now().UTC(),
+	})
+}
+
+// Minimum RAM (in GB) available for Docker to run the stack
+const minRAMGB = 8
+
 func buildConfiguration(cmd *cobra.Command) (*Configuration, error) {
 	cmdFlags := cmd.Flags()
 	config := &Configuration{}
@@ -85,19 +237,14 @@ func buildConfiguration(cmd *cobra.Command) (*Configuration, error) {
 	fmt.Printf("Detected resources available for Docker: CPUs=%d, RAM (in GB)=%d\n", sysInfo.CPUCount, sysInfo.RAMGB)
 	config.CPUs = sysInfo.CPUCount
 	config.RAM = sysInfo.RAMGB
-	if config.RAM < 8 {
-		return nil, fmt.Errorf("insufficient RAM: %d GB detected, at least 8 GB is recommended", config.RAM)
+	if config.RAM < minRAMGB {
+		return nil, fmt.Errorf("insufficient RAM: %d GB detected, at least %d GB is recommended", config.RAM, minRAMGB)
 	}
 
-	// Calculate resources allocation for each service
-	totalMiB := int64(config.RAM * 1024)
-	scaled, err := util.Scale(totalMiB, float64(config.CPUs))
-	if err != nil {
+	// Build configuration step by step
+	if err := setProjectName(config, cmdFlags); err != nil {
 		return nil, err
 	}
-	config.Resources = scaled
-
-	// Build configuration step by step
 	if err := setVersion(config, cmdFlags); err != nil {
 		return nil, err
 	}
@@ -128,18 +275,64 @@ func buildConfiguration(cmd *cobra.Command) (*Configuration, error) {
 	if err := setSolr(config, cmdFlags); err != nil {
 		return nil, err
 	}
+	if err := setSolrShards(config, cmdFlags); err != nil {
+		return nil, err
+	}
+	if err := setTransform(config, cmdFlags); err != nil {
+		return nil, err
+	}
+	if err := setContentStore(config, cmdFlags); err != nil {
+		return nil, err
+	}
 	if err := setActiveMQ(config, cmdFlags); err != nil {
 		return nil, err
 	}
 	if err := setAddons(config, cmdFlags); err != nil {
 		return nil, err
 	}
+	if err := setMonitoring(config, cmdFlags); err != nil {
+		return nil, err
+	}
 	if err := setDockerVolume(config, cmdFlags); err != nil {
 		return nil, err
 	}
 
+	// Every project encrypts its metadata properties with its own key, kept when generating again
+	metadataKeystore, err := loadMetadataKeystore(".")
+	if err != nil {
+		return nil, err
+	}
+	config.MetadataKeystore = metadataKeystore
+	if config.ACME, err = loadACMESettings("."); err != nil {
+		return nil, err
+	}
+	config.DockerSecrets = flags.DockerSecrets
+	config.Hardened = flags.Hardened
+
+	// Calculate resources allocation for each service
+	totalMiB := int64(config.RAM * 1024)
+	scaled, err := util.Scale(totalMiB, float64(config.CPUs), scaledServices(config), config.SolrShards)
+	if err != nil {
+		return nil, err
+	}
+	config.Resources = scaled
+
 	return config, nil
 }
+
+// Project names accepted by Docker Compose
+var projectNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func setProjectName(config *Configuration, cmdFlags *pflag.FlagSet) error {
+	if !cmdFlags.Changed("project-name") {
+		return nil
+	}
+	if !projectNamePattern.MatchString(flags.ProjectName) {
+		return fmt.Errorf("invalid project name %q: use lowercase letters, digits, '-' and '_', starting with a letter or digit", flags.ProjectName)
+	}
+	config.ProjectName = flags.ProjectName
+	return nil
+}
 func setVersion(config *Configuration, cmdFlags *pflag.FlagSet) error {
 	if cmdFlags.Changed("version") {
 		config.Version = flags.Version
@@ -157,6 +350,7 @@ func setVersion(config *Configuration, cmdFlags *pflag.FlagSet) error {
 	return nil
 }
 func setHTTPS(config *Configuration, cmdFlags *pflag.FlagSet) error {
+	config.CertHostnames = flags.CertHostnames
 	if cmdFlags.Changed("https") {
 		config.HTTPS = flags.HTTPS
 		return nil
@@ -199,25 +393,63 @@ func setPassword(config *Configuration, cmdFlags *pflag.FlagSet) error {
 	return nil
 }
 func setPort(config *Configuration, cmdFlags *pflag.FlagSet) error {
+	if flags.PortOffset < 0 {
+		return fmt.Errorf("invalid --port-offset %d: must be positive", flags.PortOffset)
+	}
+	config.PortOffset = flags.PortOffset
+
+	// Ports are probed on every interface, as the binding IP is chosen afterwards.
+	// The offset is also added to an explicit --port.
 	if cmdFlags.Changed("port") {
-		config.Port = flags.Port
+		port, err := strconv.Atoi(flags.Port)
+		if err != nil || port < 1 {
+			return fmt.Errorf("invalid port %q", flags.Port)
+		}
+		if port+config.PortOffset < 1 || port+config.PortOffset > 65535 {
+			return fmt.Errorf("invalid port %q: with --port-offset %d it becomes %d, outside 1-65535", flags.Port, config.PortOffset, port+config.PortOffset)
+		}
+		config.Port = strconv.Itoa(port + config.PortOffset)
+		if !util.IsPortFree("", port+config.PortOffset) {
+			fmt.Printf("\x1b[33;1mWARNING: HTTP port %s is already in use, next free port is %d\x1b[0m\n", config.Port, nextFreePort(port+config.PortOffset))
+		}
 		return nil
 	}
 
-	var defaultPort string
+	defaultPort := 8080
 	if config.HTTPS {
-		defaultPort = "8443"
-	} else {
-		defaultPort = "8080"
+		defaultPort = 8443
+	}
+	defaultPort = nextFreePort(defaultPort + config.PortOffset)
+
+	for {
+		answer, err := selector.RunTextInput("What HTTP port do you want to use (all the services are using the same port)?", strconv.Itoa(defaultPort))
+		if err != nil {
+			return err
+		}
+		port, err := strconv.Atoi(answer)
+		if err != nil || port < 1 || port > 65535 {
+			fmt.Printf("Invalid port %q\n", answer)
+			continue
+		}
+		if !util.IsPortFree("", port) {
+			defaultPort = nextFreePort(port)
+			fmt.Printf("Port %d is already in use, next free port is %d\n", port, defaultPort)
+			continue
+		}
+		config.Port = answer
+		return nil
 	}
+}
 
-	port, err := selector.RunTextInput("What HTTP port do you want to use (all the services are using the same port)?", defaultPort)
+// nextFreePort returns the first port from port upwards that is free on every interface
+func nextFreePort(port int) int {
+	shift, err := util.FreePortShift("", []int{port})
 	if err != nil {
-		return err
+		return port
 	}
-	config.Port = port
-	return nil
+	return port + shift
 }
+
 func setBinding(config *Configuration, cmdFlags *pflag.FlagSet) error {
 	if cmdFlags.Changed("use-binding") {
 		config.UseBinding = flags.UseBinding
@@ -249,15 +481,16 @@ func setFTP(cfg *Configuration, cmdFlags *pflag.FlagSet) error {
 	if cmdFlags.Changed("ftp") {
 		cfg.UseFtp = flags.UseFtp
 	} else {
-		useFtp, err := selector.RunYesNoSelector("Do you want to use FTP (default port is 2121)?", false)
+		useFtp, err := selector.RunYesNoSelector(fmt.Sprintf("Do you want to use FTP (default port is %d)?", defaultFtpPorts[0]+cfg.PortOffset), false)
 		if err != nil {
 			return err
 		}
 		cfg.UseFtp = useFtp
 	}
 
-	// Always set a default FTP binding IP, even if FTP is not used
+	// Always set a default FTP binding IP and ports, even if FTP is not used
 	cfg.FtpBindingIP = "0.0.0.0"
+	setFtpPorts(cfg, cfg.PortOffset)
 
 	if !cfg.UseFtp {
 		return nil
@@ -285,26 +518,121 @@ func setFTP(cfg *Configuration, cmdFlags *pflag.FlagSet) error {
 		}
 	}
 
+	return checkFtpPorts(cfg, cmdFlags)
+}
+
+// setFtpPorts shifts the default FTP control and data ports
+func setFtpPorts(cfg *Configuration, shift int) {
+	cfg.FtpPort = defaultFtpPorts[0] + shift
+	cfg.FtpDataPortFrom = defaultFtpPorts[1] + shift
+	cfg.FtpDataPortTo = defaultFtpPorts[2] + shift
+}
+
+// checkFtpPorts probes the FTP ports on the FTP binding IP and offers to move the
+// whole group to the next free range when any of them is in use
+func checkFtpPorts(cfg *Configuration, cmdFlags *pflag.FlagSet) error {
+	ports := []int{cfg.FtpPort, cfg.FtpDataPortFrom, cfg.FtpDataPortTo}
+	shift, err := util.FreePortShift(cfg.FtpBindingIP, ports)
+	if err != nil || shift == 0 {
+		return nil
+	}
+
+	inUse := fmt.Sprintf("%d/%d/%d", cfg.FtpPort, cfg.FtpDataPortFrom, cfg.FtpDataPortTo)
+	free := fmt.Sprintf("%d/%d/%d", cfg.FtpPort+shift, cfg.FtpDataPortFrom+shift, cfg.FtpDataPortTo+shift)
+	if cmdFlags.Changed("ftp") {
+		fmt.Printf("\x1b[33;1mWARNING: FTP ports %s are already in use, next free ports are %s (use --port-offset %d)\x1b[0m\n", inUse, free, cfg.PortOffset+shift)
+		return nil
+	}
+
+	useFree, err := selector.RunYesNoSelector(fmt.Sprintf("FTP ports %s are already in use, do you want to use %s instead?", inUse, free), true)
+	if err != nil {
+		return err
+	}
+	if useFree {
+		setFtpPorts(cfg, cfg.PortOffset+shift)
+	}
 	return nil
 }
-func setDatabase(config *Configuration, cmdFlags *pflag.FlagSet) error {
-	config.DbPassword = "alfresco"
 
+// Database user and database names, used unquoted in SQL statements
+var dbIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func setDatabase(config *Configuration, cmdFlags *pflag.FlagSet) error {
 	if cmdFlags.Changed("database") {
 		config.Database = flags.Database
-		return nil
+	} else {
+		database, err := selector.RunSelector(
+			"Which Database Engine do you want to use?",
+			[]string{"postgres", "mariadb"},
+		)
+		if err != nil {
+			return err
+		}
+		config.Database = database
 	}
 
-	database, err := selector.RunSelector(
-		"Which Database Engine do you want to use?",
-		[]string{"postgres", "mariadb"},
-	)
+	for _, id := range []struct{ flag, value string }{{"db-user", flags.DbUser}, {"db-name", flags.DbName}} {
+		if !dbIdentifierPattern.MatchString(id.value) {
+			return fmt.Errorf("invalid --%s %q: use letters, digits and '_', not starting with a digit", id.flag, id.value)
+		}
+	}
+	config.DbUser = flags.DbUser
+	config.DbName = flags.DbName
+
+	password, err := readDbPassword(cmdFlags)
 	if err != nil {
 		return err
 	}
-	config.Database = database
+	config.DbPassword = password
 	return nil
 }
+
+// readDbPassword returns the DB password given with --db-password or --db-password-file
+// ("-" reads it from stdin), the one of the workspace generated before in the current
+// folder, or a random one.
+func readDbPassword(cmdFlags *pflag.FlagSet) (string, error) {
+	if cmdFlags.Changed("db-password") && cmdFlags.Changed("db-password-file") {
+		return "", fmt.Errorf("use either --db-password or --db-password-file")
+	}
+
+	var password string
+	switch {
+	case cmdFlags.Changed("db-password"):
+		password = flags.DbPassword
+	case cmdFlags.Changed("db-password-file"):
+		var data []byte
+		var err error
+		if flags.DbPasswordFile == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(flags.DbPasswordFile)
+		}
+		if err != nil {
+			return "", fmt.Errorf("read DB password: %w", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	default:
+		// The database volume keeps the password it was initialized with
+		env, err := util.ReadEnvFile(".env")
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("read DB password: %w", err)
+		}
+		if password := (&Workspace{Dir: ".", Env: env}).Secret("DB_PASSWORD"); password != "" {
+			return password, nil
+		}
+		return util.GenerateRandomString(24), nil
+	}
+
+	// The password is written unquoted to .env and interpolated by Compose
+	if password == "" {
+		return "", fmt.Errorf("the DB password cannot be empty")
+	}
+	if strings.ContainsAny(password, " \t\r\n'\"$\\`") {
+		return "", fmt.Errorf("the DB password cannot contain whitespace, quotes, backslashes or '$'")
+	}
+	return password, nil
+}
+
 func setIndexing(config *Configuration, cmdFlags *pflag.FlagSet) error {
 	// Index cross locale
 	if cmdFlags.Changed("index-cross-locale") {
@@ -333,20 +661,101 @@ func setIndexing(config *Configuration, cmdFlags *pflag.FlagSet) error {
 func setSolr(config *Configuration, cmdFlags *pflag.FlagSet) error {
 	if cmdFlags.Changed("solr-comm") {
 		config.SolrComm = flags.SolrComm
+	} else {
+		solrComm, err := selector.RunSelector(
+			"Which Solr communication method do you want to use?",
+			[]string{"secret", "https"},
+		)
+		if err != nil {
+			return err
+		}
+		config.SolrComm = solrComm
+	}
+
+	switch config.SolrComm {
+	case "secret":
+		config.Secret = util.GenerateRandomString(32)
+	case "https":
+		config.SSLStores = newSSLStores()
+	}
+	return nil
+}
+func setSolrShards(config *Configuration, cmdFlags *pflag.FlagSet) error {
+	config.SolrShards = flags.SolrShards
+	config.SolrShardMethod = strings.ToUpper(flags.SolrShardMethod)
+
+	if config.SolrShards < 1 {
+		return fmt.Errorf("invalid number of Solr shards: %d", config.SolrShards)
+	}
+	if config.SolrShards > 1 && config.SolrShardMethod != "DB_ID" && config.SolrShardMethod != "ACL_ID" {
+		return fmt.Errorf("unsupported Solr shard method %q (DB_ID, ACL_ID)", flags.SolrShardMethod)
+	}
+	return nil
+}
+func setTransform(config *Configuration, cmdFlags *pflag.FlagSet) error {
+	if cmdFlags.Changed("transform") {
+		if flags.TransformMode != "aio" && flags.TransformMode != "split" {
+			return fmt.Errorf("unsupported transform mode %q (aio, split)", flags.TransformMode)
+		}
+		config.TransformMode = flags.TransformMode
 		return nil
 	}
 
-	solrComm, err := selector.RunSelector(
-		"Which Solr communication method do you want to use?",
-		[]string{"secret", "https"},
+	transformMode, err := selector.RunSelectorWithOptions(
+		"Which Transform Service deployment do you want to use?",
+		[]selector.Option{
+			{Code: "aio", Description: "Single All-In-One T-Engine"},
+			{Code: "split", Description: "Individual T-Engines (imagemagick, libreoffice, pdfrenderer, tika, misc)"},
+		},
+		false,
 	)
 	if err != nil {
 		return err
 	}
-	config.SolrComm = solrComm
-	if solrComm == "secret" {
-		config.Secret = util.GenerateRandomString(32)
+	config.TransformMode = transformMode[0].Code
+	return nil
+}
+func setContentStore(config *Configuration, cmdFlags *pflag.FlagSet) error {
+	if cmdFlags.Changed("content-store") {
+		if flags.ContentStore != "filesystem" && flags.ContentStore != "s3" {
+			return fmt.Errorf("unsupported content store %q (filesystem, s3)", flags.ContentStore)
+		}
+		config.ContentStore = flags.ContentStore
+	} else {
+		contentStore, err := selector.RunSelectorWithOptions(
+			"Where do you want to store the content?",
+			[]selector.Option{
+				{Code: "filesystem", Description: "Repository volume (alf-repo-data)"},
+				{Code: "s3", Description: "S3 bucket in a bundled MinIO service (requires the S3 Connector AMP)"},
+			},
+			false,
+		)
+		if err != nil {
+			return err
+		}
+		config.ContentStore = contentStore[0].Code
+	}
+
+	if config.ContentStore != "s3" {
+		return nil
+	}
+
+	// The S3 Connector is an Enterprise AMP, so it is not embedded in the CLI
+	if cmdFlags.Changed("s3-connector-amp") {
+		config.S3ConnectorAmp = flags.S3ConnectorAmp
+	} else {
+		amp, err := selector.RunTextInput("Enter the path to the S3 Connector AMP file", "alfresco-s3-connector.amp")
+		if err != nil {
+			return err
+		}
+		config.S3ConnectorAmp = amp
 	}
+	if _, err := os.Stat(config.S3ConnectorAmp); err != nil {
+		return fmt.Errorf("S3 Connector AMP not found: %w", err)
+	}
+
+	config.S3AccessKey = util.GenerateRandomString(20)
+	config.S3SecretKey = util.GenerateRandomString(40)
 	return nil
 }
 func setActiveMQ(config *Configuration, cmdFlags *pflag.FlagSet) error {
@@ -420,6 +829,22 @@ func setAddons(config *Configuration, cmdFlags *pflag.FlagSet) error {
 	config.Addons = addonCodes
 	return nil
 }
+func setMonitoring(config *Configuration, cmdFlags *pflag.FlagSet) error {
+	if cmdFlags.Changed("monitoring") {
+		config.Monitoring = flags.Monitoring
+	} else {
+		monitoring, err := selector.RunYesNoSelector("Do you want to add monitoring (Prometheus and Grafana)?", false)
+		if err != nil {
+			return err
+		}
+		config.Monitoring = monitoring
+	}
+
+	if config.Monitoring {
+		config.GrafanaPassword = util.GenerateRandomString(16)
+	}
+	return nil
+}
 func setDockerVolume(config *Configuration, cmdFlags *pflag.FlagSet) error {
 	// Hard rule for Windows ─ always Docker volumes
 	if util.IsWindows() {
@@ -484,13 +909,36 @@ func generateConfigFiles(cfg *Configuration) error {
 		}
 	}
 
-	// 3 - render each template to its output file
+	// 3 - render compose.yaml once to read its depends_on entries, which decide the
+	// networks of every service in the files rendered below
+	var draft bytes.Buffer
+	if err := root.Lookup("compose.yaml.tmpl").Execute(&draft, cfg); err != nil {
+		return fmt.Errorf("execute templates/compose.yaml.tmpl: %w", err)
+	}
+	compose, err := util.ParseComposeFile("compose.yaml", draft.Bytes())
+	if err != nil {
+		return err
+	}
+	cfg.ServiceNetworks = cfg.serviceNetworks(compose)
+
+	// 4 - render each template to its output file
 	for _, src := range paths {
 		rel := strings.TrimPrefix(src, "templates/") // "alfresco/Dockerfile.tmpl"
 
+		if strings.HasPrefix(rel, "monitoring/") && !cfg.Monitoring {
+			continue
+		}
+		if strings.HasPrefix(rel, "monitoring/activemq/") && !cfg.UseActiveMQ {
+			continue
+		}
+		// ActiveMQ is only built to read Docker secrets, or by the monitoring templates
+		if strings.HasPrefix(rel, "activemq/") && (!cfg.UseActiveMQ || !cfg.DockerSecrets || cfg.Monitoring) {
+			continue
+		}
+
 		if filepath.Base(rel) == "create_volumes.sh.tmpl" {
 			if util.IsLinux() {
-				fmt.Printf("\x1b[33;1mWARNING: Before starting Alfresco for the first time, run 'sudo ./create-volumes.sh'\x1b[0m\n")
+				fmt.Printf("\x1b[33;1mWARNING: Before starting Alfresco for the first time, run 'sudo ./create_volumes.sh' or start it with 'alf up'\x1b[0m\n")
 			} else {
 				continue
 			}
@@ -501,10 +949,19 @@ func generateConfigFiles(cfg *Configuration) error {
 		if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
 			return fmt.Errorf("mkdir %s: %w", filepath.Dir(outPath), err)
 		}
-		out, err := os.Create(outPath)
+		// .env holds the passwords, only its owner can read it
+		perm := os.FileMode(0o644)
+		if outPath == ".env" {
+			perm = 0o600
+		}
+		out, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
 		if err != nil {
 			return fmt.Errorf("create %s: %w", outPath, err)
 		}
+		if err := out.Chmod(perm); err != nil {
+			out.Close()
+			return fmt.Errorf("chmod %s: %w", outPath, err)
+		}
 
 		if err := root.Lookup(rel).Execute(out, cfg); err != nil {
 			out.Close()
@@ -513,7 +970,7 @@ func generateConfigFiles(cfg *Configuration) error {
 		out.Close()
 	}
 
-	// 4 - handle binary files and addons
+	// 5 - handle binary files and addons
 	if cfg.Database == "mariadb" {
 		if err := copyBinary("templates/libs/mariadb-java-client-2.7.4.jar",
 			"libs/mariadb-java-client-2.7.4.jar"); err != nil {
@@ -526,18 +983,57 @@ func generateConfigFiles(cfg *Configuration) error {
 			return fmt.Errorf("copy ActiveMQ local library: %w", err)
 		}
 	}
+	if err := writeMetadataKeystore(".", cfg.MetadataKeystore); err != nil {
+		return fmt.Errorf("create metadata keystore: %w", err)
+	}
+	if cfg.DockerSecrets {
+		if err := writeDockerSecrets(".", cfg.SecretFiles()); err != nil {
+			return fmt.Errorf("create Docker secrets: %w", err)
+		}
+		// Build contexts of the images starting with the wrapper, and the folder mounted in the T-Engines
+		dirs := []string{"alfresco", "search"}
+		if cfg.UseActiveMQ && cfg.Monitoring {
+			dirs = append(dirs, "monitoring/activemq")
+		} else if cfg.UseActiveMQ {
+			dirs = append(dirs, "activemq")
+		}
+		if cfg.TransformSecrets() {
+			dirs = append(dirs, "transform")
+		}
+		for _, dir := range dirs {
+			if err := copyBinary(secretsEntrypoint, filepath.Join(dir, filepath.Base(secretsEntrypoint))); err != nil {
+				return fmt.Errorf("copy secrets entrypoint: %w", err)
+			}
+		}
+	}
 	if cfg.SolrComm == "https" {
-		if err := copyFolder("templates/keystores", "keystores", TemplateFS); err != nil {
-			return fmt.Errorf("copy mTLS keystores: %w", err)
+		var solrServices []string
+		for _, solr := range cfg.SolrInstances() {
+			solrServices = append(solrServices, solr.Name)
+		}
+		if err := writeSSLKeystores(".", solrServices, cfg.SSLStores); err != nil {
+			return fmt.Errorf("create mTLS keystores: %w", err)
 		}
 	}
 	if cfg.HTTPS {
-		if err := copyFolder("templates/config/cert", "config/cert", TemplateFS); err != nil {
-			return fmt.Errorf("copy HTTPs certificates: %w", err)
+		if err := writeProxyCertificate(".", cfg.ProjectName, proxyCertNames(cfg)); err != nil {
+			return fmt.Errorf("create HTTPS certificate: %w", err)
 		}
 	}
 
-	// 5 - copy addons
+	if cfg.Monitoring {
+		if err := copyFolder("templates/monitoring/grafana/dashboards", "monitoring/grafana/dashboards", TemplateFS); err != nil {
+			return fmt.Errorf("copy Grafana dashboards: %w", err)
+		}
+	}
+	if cfg.ContentStore == "s3" {
+		amp := filepath.Join("alfresco/modules/amps", filepath.Base(cfg.S3ConnectorAmp))
+		if err := copyFile(cfg.S3ConnectorAmp, amp); err != nil {
+			return fmt.Errorf("copy S3 Connector AMP: %w", err)
+		}
+	}
+
+	// 6 - copy addons
 	if slices.Contains(cfg.Addons, "alf-tengine-ocr") {
 		if err := copyBinary("templates/addons/jars/embed-metadata-action-1.0.0.jar",
 			"alfresco/modules/jars/tengine-ocr-1.1.0.jar"); err != nil {
@@ -614,6 +1110,31 @@ func copyBinary(srcPath string, outPath string) error {
 	return nil
 }
 
+// copyFile copies a file from the local filesystem into the output folder
+func copyFile(srcPath string, outPath string) error {
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", srcPath, err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return fmt.Errorf("mkdir %s: %w", filepath.Dir(outPath), err)
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", outPath, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("copy %s: %w", outPath, err)
+	}
+
+	return nil
+}
+
 // CopyFolder copies all files and directories from srcDir to dstDir
 func copyFolder(srcDir, dstDir string, sourceFS fs.FS) error {
 	return fs.WalkDir(sourceFS, srcDir, func(path string, d fs.DirEntry, err error) error {
@@ -663,17 +1184,23 @@ func copyFolder(srcDir, dstDir string, sourceFS fs.FS) error {
 		  --index-content=true \
 		  --index-cross-locale=true \
 		  --solr-comm=secret \
+		  --transform=aio \
+		  --content-store=filesystem \
 		  --activemq=false \
 		  --addons=js-console \
+		  --monitoring=false \
 		  --docker-volume=false
 */
 func init() {
 	// Basic configuration flags
 	dockerComposeCmd.Flags().StringVar(&flags.Version, "version", "", "ACS version (25.2, 25.1)")
+	dockerComposeCmd.Flags().StringVar(&flags.ProjectName, "project-name", "", "Compose project name, also prefixing the named volumes (default: folder name)")
 	dockerComposeCmd.Flags().BoolVar(&flags.HTTPS, "https", false, "Enable HTTPS")
+	dockerComposeCmd.Flags().StringSliceVar(&flags.CertHostnames, "cert-hostnames", nil, "Extra hostnames or IPs of the HTTPS certificate, besides the server name and binding IP")
 	dockerComposeCmd.Flags().StringVar(&flags.Server, "server", "", "Server name")
 	dockerComposeCmd.Flags().StringVar(&flags.AdminPassword, "password", "", "Admin password")
 	dockerComposeCmd.Flags().StringVar(&flags.Port, "port", "", "HTTP port")
+	dockerComposeCmd.Flags().IntVar(&flags.PortOffset, "port-offset", 0, "Number added to every exposed port (HTTP, including an explicit --port, and FTP) to run stacks side by side")
 
 	// Network binding flags
 	dockerComposeCmd.Flags().BoolVar(&flags.UseBinding, "use-binding", false, "Use custom HTTP binding IP")
@@ -685,18 +1212,36 @@ func init() {
 
 	// Database and indexing flags
 	dockerComposeCmd.Flags().StringVar(&flags.Database, "database", "postgres", "Database Engine (postgres, mariadb)")
+	dockerComposeCmd.Flags().StringVar(&flags.DbUser, "db-user", "alfresco", "Database user")
+	dockerComposeCmd.Flags().StringVar(&flags.DbName, "db-name", "alfresco", "Database name")
+	dockerComposeCmd.Flags().StringVar(&flags.DbPassword, "db-password", "", "Database password (default: random)")
+	dockerComposeCmd.Flags().StringVar(&flags.DbPasswordFile, "db-password-file", "", "File containing the database password, '-' to read it from stdin")
 	dockerComposeCmd.Flags().BoolVar(&flags.IndexCrossLocale, "index-cross-locale", true, "Enable cross-locale indexing")
 	dockerComposeCmd.Flags().BoolVar(&flags.IndexContent, "index-content", true, "Enable full-text indexing")
 	dockerComposeCmd.Flags().StringVar(&flags.SolrComm, "solr-comm", "", "Solr communication method (secret|https)")
+	dockerComposeCmd.Flags().IntVar(&flags.SolrShards, "solr-shards", 1, "Number of Solr shards")
+	dockerComposeCmd.Flags().StringVar(&flags.SolrShardMethod, "solr-shard-method", "DB_ID", "Solr sharding method (DB_ID, ACL_ID)")
+
+	// Transform configuration flags
+	dockerComposeCmd.Flags().StringVar(&flags.TransformMode, "transform", "", "Transform Service deployment (aio, split)")
+
+	// Content store configuration flags
+	dockerComposeCmd.Flags().StringVar(&flags.ContentStore, "content-store", "", "Content store (filesystem, s3)")
+	dockerComposeCmd.Flags().StringVar(&flags.S3ConnectorAmp, "s3-connector-amp", "", "Path to the S3 Connector AMP (content-store s3)")
 
 	// ActiveMQ configuration flags
 	dockerComposeCmd.Flags().BoolVar(&flags.UseActiveMQ, "activemq", false, "Enable ActiveMQ")
 	dockerComposeCmd.Flags().StringVar(&flags.AmqUser, "amq-user", "admin", "ActiveMQ username")
 	dockerComposeCmd.Flags().StringVar(&flags.AmqPassword, "amq-password", "admin", "ActiveMQ password")
 
+	// Monitoring flags
+	dockerComposeCmd.Flags().BoolVar(&flags.Monitoring, "monitoring", false, "Enable Prometheus and Grafana monitoring")
+
 	// Addon and volume flags
 	dockerComposeCmd.Flags().StringSliceVarP(&flags.Addons, "addons", "a", nil, "Comma-separated list of addon codes")
 	dockerComposeCmd.Flags().BoolVar(&flags.UseDockerVolume, "docker-volume", true, "Use Docker-managed volumes")
+	dockerComposeCmd.Flags().BoolVar(&flags.DockerSecrets, "docker-secrets", false, "Write credentials as Docker secrets under secrets/ instead of .env")
+	dockerComposeCmd.Flags().BoolVar(&flags.Hardened, "hardened", false, "Harden the containers, keep backend services on internal networks and add nginx security headers")
 
 	rootCmd.AddCommand(dockerComposeCmd)
 }
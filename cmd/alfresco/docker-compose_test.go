@@ -0,0 +1,236 @@
+package alfresco
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/spf13/pflag"
+)
+
+// renderWorkspace generates a workspace for cfg in a temporary folder, filling in the
+// answers the wizard would give, and returns its parsed compose.yaml
+func renderWorkspace(t *testing.T, cfg *Configuration) *util.ComposeFile {
+	t.Helper()
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	TemplateFS = os.DirFS(root)
+
+	defaults := map[*string]string{
+		&cfg.Version: "25.2", &cfg.Server: "localhost", &cfg.Port: "8080",
+		&cfg.Database: "postgres", &cfg.DbUser: "alfresco", &cfg.DbName: "alfresco", &cfg.DbPassword: "alfresco",
+		&cfg.AdminPassword: util.ComputeHashPassword("admin"), &cfg.SolrComm: "secret", &cfg.SolrShardMethod: "DB_ID",
+		&cfg.TransformMode: "aio", &cfg.ContentStore: "filesystem",
+	}
+	for field, value := range defaults {
+		if *field == "" {
+			*field = value
+		}
+	}
+	cfg.SolrShards = max(cfg.SolrShards, 1)
+	cfg.MetadataKeystore = newMetadataKeystore()
+	switch cfg.SolrComm {
+	case "secret":
+		cfg.Secret = util.GenerateRandomString(32)
+	case "https":
+		cfg.SSLStores = newSSLStores()
+	}
+	if cfg.ContentStore == "s3" {
+		cfg.S3ConnectorAmp = filepath.Join(t.TempDir(), "alfresco-s3-connector.amp")
+		if err := os.WriteFile(cfg.S3ConnectorAmp, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cfg.Resources, err = util.Scale(16*1024, 8, scaledServices(cfg), cfg.SolrShards); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Chdir(t.TempDir())
+	if err := generateConfigFiles(cfg); err != nil {
+		t.Fatal(err)
+	}
+	compose, err := util.ReadComposeFile("compose.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compose
+}
+
+func TestGeneratedComposeFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Configuration
+		services []string
+	}{
+		{"default", Configuration{}, []string{"postgres", "transform-core-aio", "alfresco", "solr6", "share", "content-app", "control-center", "proxy"}},
+		{"shards", Configuration{Database: "mariadb", SolrShards: 3, SolrShardMethod: "ACL_ID"}, []string{"mariadb", "solr6", "solr6-1", "solr6-2"}},
+		{"split transforms", Configuration{TransformMode: "split", UseActiveMQ: true, AmqUser: "admin", AmqPassword: "admin"},
+			[]string{"activemq", "transform-imagemagick", "transform-libreoffice", "transform-pdfrenderer", "transform-tika", "transform-misc"}},
+		{"s3", Configuration{ContentStore: "s3"}, []string{"minio", "minio-init"}},
+		{"monitoring", Configuration{Monitoring: true, UseActiveMQ: true, GrafanaPassword: "grafana", Addons: []string{"alf-tengine-ocr"}},
+			[]string{"postgres-exporter", "prometheus", "grafana", "transform-ocr"}},
+		{"hardened with secrets", Configuration{Hardened: true, DockerSecrets: true, HTTPS: true, SolrComm: "https", UseFtp: true, UseActiveMQ: true, AmqUser: "admin", AmqPassword: "admin",
+			Addons: []string{"alf-tengine-ocr"}},
+			[]string{"activemq", "alfresco", "solr6", "proxy", "transform-core-aio", "transform-ocr"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compose := renderWorkspace(t, &tt.cfg)
+			services := compose.Services()
+			for _, s := range tt.services {
+				if !slices.Contains(services, s) {
+					t.Errorf("service %s missing from %v", s, services)
+				}
+			}
+
+			for _, s := range services {
+				if compose.Service(s).String("image") == "" && compose.Service(s).Get("build") == nil {
+					t.Errorf("service %s has neither image nor build", s)
+				}
+				for _, dep := range compose.DependsOn(s) {
+					if !compose.HasService(dep) {
+						t.Errorf("service %s depends on undefined service %s", s, dep)
+					}
+				}
+			}
+
+			started := 0
+			for _, group := range compose.StartupOrder() {
+				started += len(group)
+			}
+			if started != len(services) {
+				t.Errorf("StartupOrder covers %d of %d services", started, len(services))
+			}
+
+			if opts := compose.Root.String("services", "alfresco", "environment", "JAVA_OPTS"); !strings.Contains(opts, "-Dindex.subsystem.name=solr6") {
+				t.Errorf("alfresco JAVA_OPTS not parsed: %q", opts)
+			}
+			// Prometheus reaches its scrape targets without depending on them
+			if compose.HasService("prometheus") {
+				if networks := compose.Root.Strings("services", "prometheus", "networks"); !slices.Equal(networks, []string{networkBackend, networkFrontend, networkMessaging}) {
+					t.Errorf("prometheus networks = %v", networks)
+				}
+			}
+			if ports := compose.Root.Strings("services", "proxy", "ports"); len(ports) != 1 || !strings.HasSuffix(ports[0], ":8080:8080") {
+				t.Errorf("proxy ports = %v", ports)
+			}
+			// T-Engines authenticate to ActiveMQ with the credentials of the broker
+			if tt.cfg.TransformSecrets() {
+				for _, engine := range append(tt.cfg.TransformEngines(), TransformEngine{Name: "transform-ocr"}) {
+					if secrets := compose.Root.Strings("services", engine.Name, "secrets"); !slices.Equal(secrets, []string{"activemq_user", "activemq_password"}) {
+						t.Errorf("%s secrets = %v", engine.Name, secrets)
+					}
+					env := compose.Root.String("services", engine.Name, "environment", "SECRET_ENV")
+					if !strings.Contains(env, "ACTIVEMQ_USER=/run/secrets/activemq_user") || !strings.Contains(env, "ACTIVEMQ_PASSWORD=/run/secrets/activemq_password") {
+						t.Errorf("%s SECRET_ENV = %q", engine.Name, env)
+					}
+				}
+			} else if tt.cfg.UseActiveMQ && tt.cfg.AmqUser != "" {
+				if user := compose.Root.String("services", tt.cfg.TransformEngines()[0].Name, "environment", "ACTIVEMQ_USER"); user == "" {
+					t.Error("T-Engine without ACTIVEMQ_USER")
+				}
+			}
+			for _, file := range []string{".env", metadataKeystoreFile} {
+				if info, err := os.Stat(file); err != nil {
+					t.Error(err)
+				} else if info.Mode().Perm() != 0o600 {
+					t.Errorf("%s mode = %v, want 0600", file, info.Mode().Perm())
+				}
+			}
+		})
+	}
+}
+
+func TestSetPort(t *testing.T) {
+	tests := []struct {
+		port   string
+		offset int
+		want   string // "" when the port is rejected
+	}{
+		{"8080", 0, "8080"},
+		{"8080", 100, "8180"},
+		{"65535", 0, "65535"},
+		{"65500", 100, ""},
+		{"0", 0, ""},
+		{"0", 100, ""},
+		{"-50", 100, ""},
+		{"http", 0, ""},
+		{"8080", -1, ""},
+	}
+	saved := flags
+	t.Cleanup(func() { flags = saved })
+	for _, tt := range tests {
+		cmdFlags := pflag.NewFlagSet("docker-compose", pflag.ContinueOnError)
+		cmdFlags.String("port", "", "")
+		if err := cmdFlags.Set("port", tt.port); err != nil {
+			t.Fatal(err)
+		}
+		flags.Port, flags.PortOffset = tt.port, tt.offset
+
+		config := &Configuration{}
+		err := setPort(config, cmdFlags)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("--port %s --port-offset %d: accepted as %s", tt.port, tt.offset, config.Port)
+		case tt.want != "" && err != nil:
+			t.Errorf("--port %s --port-offset %d: %v", tt.port, tt.offset, err)
+		case tt.want != "" && config.Port != tt.want:
+			t.Errorf("--port %s --port-offset %d: got %s, want %s", tt.port, tt.offset, config.Port, tt.want)
+		}
+	}
+}
+
+func TestReadDbPasswordKeepsExisting(t *testing.T) {
+	t.Chdir(t.TempDir())
+	cmdFlags := pflag.NewFlagSet("docker-compose", pflag.ContinueOnError)
+
+	password, err := readDbPassword(cmdFlags)
+	if err != nil || len(password) != 24 {
+		t.Fatalf("fresh workspace: got %q, %v, want a random password", password, err)
+	}
+
+	if err := os.WriteFile(".env", []byte("DB_USER=alfresco\nDB_PASSWORD=from-env\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if password, err := readDbPassword(cmdFlags); err != nil || password != "from-env" {
+		t.Errorf(".env: got %q, %v, want from-env", password, err)
+	}
+
+	if err := writeDockerSecrets(".", map[string]string{dockerSecretFiles["DB_PASSWORD"]: "from-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if password, err := readDbPassword(cmdFlags); err != nil || password != "from-secret" {
+		t.Errorf("Docker secret: got %q, %v, want from-secret", password, err)
+	}
+}
+
+func TestDefaultStackAllocation(t *testing.T) {
+	// Allocation of 16 GB and 8 CPUs before the budget followed the deployed services
+	want := map[string]util.Resource{
+		"activemq":           {Limits: util.CPUMem{CPU: 0.76, MiB: 1489}, Reservations: util.CPUMem{CPU: 0.38, MiB: 744}},
+		"alfresco":           {Limits: util.CPUMem{CPU: 1.52, MiB: 4468}, Reservations: util.CPUMem{CPU: 0.76, MiB: 2978}},
+		"content-app":        {Limits: util.CPUMem{CPU: 0.38, MiB: 744}, Reservations: util.CPUMem{CPU: 0.19, MiB: 372}},
+		"control-center":     {Limits: util.CPUMem{CPU: 0.38, MiB: 744}, Reservations: util.CPUMem{CPU: 0.19, MiB: 372}},
+		"database":           {Limits: util.CPUMem{CPU: 0.76, MiB: 1489}, Reservations: util.CPUMem{CPU: 0.38, MiB: 744}},
+		"proxy":              {Limits: util.CPUMem{CPU: 0.38, MiB: 744}, Reservations: util.CPUMem{CPU: 0.19, MiB: 372}},
+		"share":              {Limits: util.CPUMem{CPU: 0.76, MiB: 1489}, Reservations: util.CPUMem{CPU: 0.38, MiB: 744}},
+		"solr6":              {Limits: util.CPUMem{CPU: 1.52, MiB: 2234}, Reservations: util.CPUMem{CPU: 0.76, MiB: 1117}},
+		"transform-core-aio": {Limits: util.CPUMem{CPU: 1.52, MiB: 2978}, Reservations: util.CPUMem{CPU: 0.76, MiB: 1489}},
+	}
+	for _, activemq := range []bool{false, true} {
+		config := &Configuration{TransformMode: "aio", UseActiveMQ: activemq}
+		got, err := util.Scale(16*1024, 8, scaledServices(config), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(got, want) {
+			t.Errorf("ActiveMQ %v: got %+v, want %+v", activemq, got, want)
+		}
+	}
+}
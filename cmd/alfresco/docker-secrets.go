@@ -0,0 +1,124 @@
+package alfresco
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aborroy/alf-cli/internal/util"
+)
+
+// Folder of the Docker secrets written with --docker-secrets, relative to the workspace
+const dockerSecretsDir = "secrets"
+
+// Entrypoint wrapper reading the Docker secrets in the repository, Solr and ActiveMQ images
+const secretsEntrypoint = "templates/scripts/secrets-entrypoint.sh"
+
+// Shell expansions of the MariaDB passwords inside the container, set in the environment or as Docker secrets
+const (
+	mariadbPassword     = `"${MYSQL_PASSWORD:-$(cat "$MYSQL_PASSWORD_FILE")}"`
+	mariadbRootPassword = `"${MYSQL_ROOT_PASSWORD:-$(cat "$MYSQL_ROOT_PASSWORD_FILE")}"`
+)
+
+// dockerSecretFiles maps the .env variables moved to Docker secrets to their file under secrets/
+var dockerSecretFiles = map[string]string{
+	"ADMIN_PASSWORD":          "admin_password",
+	"DB_PASSWORD":             "db_password",
+	"SECURE_COMMS_SECRET":     "solr_secret",
+	"ACTIVEMQ_ADMIN_USER":     "activemq_user",
+	"ACTIVEMQ_ADMIN_PASSWORD": "activemq_password",
+}
+
+// SecretFiles returns the content of the Docker secrets used by the configuration, by file name
+func (c *Configuration) SecretFiles() map[string]string {
+	files := map[string]string{
+		dockerSecretFiles["ADMIN_PASSWORD"]: c.AdminPassword,
+		dockerSecretFiles["DB_PASSWORD"]:    c.DbPassword,
+	}
+	if c.SolrComm == "secret" {
+		files[dockerSecretFiles["SECURE_COMMS_SECRET"]] = c.Secret
+	}
+	if c.UseActiveMQ && c.AmqUser != "" {
+		files[dockerSecretFiles["ACTIVEMQ_ADMIN_USER"]] = c.AmqUser
+	}
+	if c.UseActiveMQ && c.AmqPassword != "" {
+		files[dockerSecretFiles["ACTIVEMQ_ADMIN_PASSWORD"]] = c.AmqPassword
+	}
+	return files
+}
+
+// writeDockerSecrets writes every secret to its own file, only readable by the owner.
+// Compose bind mounts file secrets keeping their host owner and mode, so the services read
+// them as root: the databases through their *_FILE variables, the exporters running as root,
+// and the other images through secrets-entrypoint.sh, which exports them and then starts
+// the service as the image user.
+func writeDockerSecrets(dir string, files map[string]string) error {
+	secretsDir := filepath.Join(dir, dockerSecretsDir)
+	if err := os.MkdirAll(secretsDir, 0o700); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if err := os.WriteFile(filepath.Join(secretsDir, name), []byte(files[name]), 0o600); err != nil {
+			return fmt.Errorf("write secret %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// secretFile returns the path of the Docker secret holding a .env variable, or "" when
+// the workspace keeps it in .env
+func (w *Workspace) secretFile(key string) string {
+	name, ok := dockerSecretFiles[key]
+	if !ok {
+		return ""
+	}
+	path := filepath.Join(w.Dir, dockerSecretsDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// Secret returns a credential from its Docker secret, or from .env
+func (w *Workspace) Secret(key string) string {
+	if path := w.secretFile(key); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			return strings.TrimRight(string(data), "\r\n")
+		}
+	}
+	return w.Env[key]
+}
+
+// UpdateSecrets writes new credentials to their Docker secret, or to .env
+func (w *Workspace) UpdateSecrets(values map[string]string) error {
+	env := make(map[string]string)
+	for key, value := range values {
+		if path := w.secretFile(key); path != "" {
+			if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+				return err
+			}
+		} else {
+			env[key] = value
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	return util.UpdateEnvFile(filepath.Join(w.Dir, ".env"), env)
+}
+
+// ServicesUsingSecret returns the services reading a .env variable, interpolated or as a Docker secret
+func (w *Workspace) ServicesUsingSecret(key string) []string {
+	services := w.Compose.ServicesUsing(key)
+	if name, ok := dockerSecretFiles[key]; ok {
+		for _, service := range w.Compose.ServicesWithSecret(name) {
+			if !slices.Contains(services, service) {
+				services = append(services, service)
+			}
+		}
+	}
+	return services
+}
@@ -0,0 +1,257 @@
+package alfresco
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+// Free disk space (in GB) recommended to pull the images and store the volumes
+const minDiskGB = 20
+
+var doctorOptions struct {
+	Port         string
+	PortOffset   int
+	BindingIP    string
+	UseFtp       bool
+	FtpBindingIP string
+	Output       string
+}
+
+var doctorFailStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#ff5f5f"))
+
+// Severity of a doctor check result
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// doctorCheck is the outcome of a single preflight check
+type doctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that this host can generate and run an Alfresco stack",
+	Long: `Run preflight checks before generating or starting a stack: Docker and Docker Compose
+versions, HTTP and FTP ports on the binding IP, free disk space, memory available to
+Docker, cgroup detection and whether ./data can be owned by the container users.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runDoctor,
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	var checks []doctorCheck
+	checks = append(checks, checkDocker()...)
+	checks = append(checks, checkPorts()...)
+	checks = append(checks, checkDisk(doctorOptions.Output))
+	checks = append(checks, checkResources()...)
+	checks = append(checks, checkDataOwnership(doctorOptions.Output))
+
+	failures := 0
+	for _, c := range checks {
+		var status string
+		switch c.Status {
+		case checkOK:
+			status = probeOKStyle.Render("✔")
+		case checkWarn:
+			status = probePendingStyle.Render("!")
+		default:
+			status = doctorFailStyle.Render("✖")
+			failures++
+		}
+		fmt.Printf("  %s %-22s %s\n", status, c.Name, c.Detail)
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d check(s) failed", failures)
+	}
+	return nil
+}
+
+// checkDocker reports the Docker client, daemon and Compose versions
+func checkDocker() []doctorCheck {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return []doctorCheck{{"Docker", checkFail, "docker not found in PATH"}}
+	}
+
+	var checks []doctorCheck
+	client, _ := commandOutput("docker", "version", "--format", "{{.Client.Version}}")
+	if server, err := commandOutput("docker", "version", "--format", "{{.Server.Version}}"); err != nil {
+		checks = append(checks, doctorCheck{"Docker", checkFail, fmt.Sprintf("client %s, daemon not reachable", client)})
+	} else {
+		checks = append(checks, doctorCheck{"Docker", checkOK, fmt.Sprintf("client %s, daemon %s", client, server)})
+	}
+
+	if version, err := commandOutput("docker", "compose", "version", "--short"); err == nil {
+		checks = append(checks, doctorCheck{"Docker Compose", checkOK, "v2 " + version})
+	} else if version, err := commandOutput("docker-compose", "version", "--short"); err == nil {
+		checks = append(checks, doctorCheck{"Docker Compose", checkWarn, "v1 " + version + " is deprecated, install Compose v2"})
+	} else {
+		checks = append(checks, doctorCheck{"Docker Compose", checkFail, "neither 'docker compose' nor 'docker-compose' found"})
+	}
+	return checks
+}
+
+// checkPorts verifies that the HTTP (and FTP) ports can be bound on their binding IPs
+func checkPorts() []doctorCheck {
+	port, err := strconv.Atoi(doctorOptions.Port)
+	if err != nil {
+		return []doctorCheck{{"HTTP port", checkFail, fmt.Sprintf("invalid port %q", doctorOptions.Port)}}
+	}
+	checks := []doctorCheck{portCheck("HTTP port", doctorOptions.BindingIP, port+doctorOptions.PortOffset)}
+	if doctorOptions.UseFtp {
+		for _, port := range defaultFtpPorts {
+			checks = append(checks, portCheck("FTP port", doctorOptions.FtpBindingIP, port+doctorOptions.PortOffset))
+		}
+	}
+	return checks
+}
+
+func portCheck(name, ip string, port int) doctorCheck {
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
+	if err := util.CheckPortFree(ip, strconv.Itoa(port)); err != nil {
+		return doctorCheck{name, checkFail, fmt.Sprintf("%s is not available: %v", addr, err)}
+	}
+	return doctorCheck{name, checkOK, addr + " is free"}
+}
+
+// checkDisk reports the free space of the filesystem holding dir
+func checkDisk(dir string) doctorCheck {
+	free, err := util.FreeDiskBytes(existingParent(dir))
+	if err != nil {
+		return doctorCheck{"Disk space", checkWarn, fmt.Sprintf("cannot read free space of %s: %v", dir, err)}
+	}
+	gb := free >> 30
+	if gb < minDiskGB {
+		return doctorCheck{"Disk space", checkWarn, fmt.Sprintf("%d GB free in %s, at least %d GB is recommended", gb, dir, minDiskGB)}
+	}
+	return doctorCheck{"Disk space", checkOK, fmt.Sprintf("%d GB free in %s", gb, dir)}
+}
+
+// checkResources reports the memory, CPUs and cgroup detection of DockerResourceDetector
+func checkResources() []doctorCheck {
+	detector := util.NewDockerResourceDetector()
+	sysInfo, err := detector.GetSystemInfo()
+
+	var checks []doctorCheck
+	switch {
+	case sysInfo.RAMGB <= 0:
+		checks = append(checks, doctorCheck{"Docker memory", checkFail, fmt.Sprintf("detection returned %d GB (%d bytes)", sysInfo.RAMGB, sysInfo.RAMBytes)})
+	case sysInfo.RAMGB < minRAMGB:
+		checks = append(checks, doctorCheck{"Docker memory", checkFail, fmt.Sprintf("%d GB, at least %d GB is required", sysInfo.RAMGB, minRAMGB)})
+	default:
+		checks = append(checks, doctorCheck{"Docker memory", checkOK, fmt.Sprintf("%d GB (minimum %d GB)", sysInfo.RAMGB, minRAMGB)})
+	}
+	checks = append(checks, doctorCheck{"Docker CPUs", checkOK, fmt.Sprintf("%d", sysInfo.CPUCount)})
+
+	if util.IsLinux() {
+		version := detector.CgroupVersion()
+		switch {
+		case version == "":
+			checks = append(checks, doctorCheck{"cgroup", checkWarn, "no cgroup hierarchy found, host totals are used"})
+		case err != nil:
+			checks = append(checks, doctorCheck{"cgroup", checkWarn, fmt.Sprintf("cgroup %s, detection errors: %v", version, err)})
+		default:
+			checks = append(checks, doctorCheck{"cgroup", checkOK, "cgroup " + version})
+		}
+	} else if err != nil {
+		checks = append(checks, doctorCheck{"Docker Desktop", checkWarn, err.Error()})
+	}
+	return checks
+}
+
+// checkDataOwnership verifies that folders in dir can be given to the container users,
+// as create_volumes.sh does for the ./data bind mounts. Only relevant on Linux.
+func checkDataOwnership(dir string) doctorCheck {
+	const name = "./data ownership"
+	if !util.IsLinux() {
+		return doctorCheck{name, checkOK, "not needed, Docker Desktop maps file ownership"}
+	}
+
+	parent := existingParent(dir)
+	probe, err := os.MkdirTemp(parent, ".alf-doctor-")
+	if err != nil {
+		return doctorCheck{name, checkFail, fmt.Sprintf("%s is not writable: %v", parent, err)}
+	}
+	defer os.RemoveAll(probe)
+
+	var denied []string
+	for volume, owner := range volumeOwners {
+		uid, gid := parseOwner(owner)
+		if err := os.Chown(probe, uid, gid); err != nil {
+			denied = append(denied, volume+" ("+owner+")")
+		}
+	}
+	switch {
+	case len(denied) == 0:
+		return doctorCheck{name, checkOK, "container UID/GIDs can own folders in " + parent}
+	case os.Geteuid() != 0:
+		return doctorCheck{name, checkWarn, "not root: use 'alf up' or 'sudo ./create_volumes.sh' to prepare ./data"}
+	default:
+		return doctorCheck{name, checkFail, fmt.Sprintf("filesystem of %s refuses chown for %s", parent, strings.Join(denied, ", "))}
+	}
+}
+
+// parseOwner splits a "uid[:gid]" owner; a missing gid keeps the current group (-1)
+func parseOwner(owner string) (int, int) {
+	uid, gid := -1, -1
+	u, g, found := strings.Cut(owner, ":")
+	fmt.Sscan(u, &uid)
+	if found {
+		fmt.Sscan(g, &gid)
+	}
+	return uid, gid
+}
+
+// existingParent returns dir, or its closest existing ancestor when it is not created yet
+func existingParent(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	for {
+		if _, err := os.Stat(abs); err == nil || filepath.Dir(abs) == abs {
+			return abs
+		}
+		abs = filepath.Dir(abs)
+	}
+}
+
+// commandOutput runs a command and returns its trimmed standard output
+func commandOutput(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func init() {
+	doctorCmd.Flags().StringVar(&doctorOptions.Port, "port", "8080", "HTTP port to check")
+	doctorCmd.Flags().IntVar(&doctorOptions.PortOffset, "port-offset", 0, "Number added to the checked ports, as in docker-compose --port-offset")
+	doctorCmd.Flags().StringVar(&doctorOptions.BindingIP, "binding-ip", "0.0.0.0", "HTTP binding IP")
+	doctorCmd.Flags().BoolVar(&doctorOptions.UseFtp, "ftp", false, "Check FTP ports (2121, 2433, 2434)")
+	doctorCmd.Flags().StringVar(&doctorOptions.FtpBindingIP, "ftp-binding-ip", "0.0.0.0", "FTP binding IP")
+	doctorCmd.Flags().StringVarP(&doctorOptions.Output, "output", "o", ".", "Output folder for the generated workspace")
+
+	rootCmd.AddCommand(doctorCmd)
+}
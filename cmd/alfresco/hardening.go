@@ -0,0 +1,86 @@
+package alfresco
+
+// ServiceHardening holds the settings added by --hardened to a service of compose.yaml
+type ServiceHardening struct {
+	User     string   // uid the image already runs as, pinned so it cannot fall back to root
+	DropCaps bool     // cap_drop ALL
+	CapAdd   []string // Capabilities still needed by an entrypoint starting as root
+	ReadOnly bool     // Read-only root filesystem
+	Tmpfs    []string // Writable paths of a read-only root filesystem
+}
+
+// Capabilities of the entrypoints starting as root: the database ones chown their data folder
+// and step down with gosu, secrets-entrypoint.sh reads the Docker secrets (owned by the host
+// user) and steps down with setpriv
+var (
+	databaseEntrypointCaps = []string{"CHOWN", "DAC_OVERRIDE", "FOWNER", "SETGID", "SETUID"}
+	secretsEntrypointCaps  = []string{"DAC_READ_SEARCH", "SETGID", "SETUID"}
+)
+
+// Hardening returns the --hardened settings of a kind of service, named as its Resources
+// entry ("transform" for every T-Engine), or nil without --hardened
+func (c *Configuration) Hardening(kind string) *ServiceHardening {
+	if !c.Hardened {
+		return nil
+	}
+
+	h := &ServiceHardening{DropCaps: true}
+	switch kind {
+	case "database":
+		h.ReadOnly = true
+		h.Tmpfs = []string{"/var/run/postgresql", "/tmp"}
+		if c.Database == "mariadb" {
+			h.Tmpfs = []string{"/run/mysqld", "/tmp"}
+		}
+		if c.DockerSecrets {
+			h.CapAdd = databaseEntrypointCaps
+		} else {
+			h.User = "999"
+		}
+	case "database-exporter":
+		h.ReadOnly = true
+		if c.DockerSecrets {
+			// Runs as root to read the password
+			h.CapAdd = []string{"DAC_READ_SEARCH"}
+		} else {
+			h.User = "65534"
+		}
+	case "activemq":
+		// The image writes the admin credentials to its configuration on startup
+		if c.DockerSecrets {
+			h.CapAdd = secretsEntrypointCaps
+		} else {
+			h.User = "33031"
+		}
+	case "alfresco":
+		if c.DockerSecrets {
+			h.CapAdd = secretsEntrypointCaps
+		} else {
+			h.User = "33000"
+		}
+	case "solr6":
+		if c.DockerSecrets {
+			h.CapAdd = secretsEntrypointCaps
+		} else {
+			h.User = "33007"
+		}
+	case "transform":
+		if c.TransformSecrets() {
+			h.CapAdd = secretsEntrypointCaps
+		}
+	case "transform-ocr":
+		// Third-party image, only kept from gaining privileges
+		h.DropCaps = false
+	case "grafana":
+		h.User = "472"
+	case "prometheus":
+		h.User = "65534"
+		h.ReadOnly = true
+	case "proxy":
+		// The nginx master reads the certificate as root and starts the workers as nginx
+		h.CapAdd = []string{"CHOWN", "DAC_OVERRIDE", "NET_BIND_SERVICE", "SETGID", "SETUID"}
+		h.ReadOnly = true
+		h.Tmpfs = []string{"/var/cache/nginx", "/var/run", "/tmp"}
+	}
+	return h
+}
@@ -0,0 +1,214 @@
+package alfresco
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/aborroy/alf-cli/internal/util"
+)
+
+// Validity of the certificates used for the mTLS communication between the repository and Solr
+const sslValidity = 10 * 365 * 24 * time.Hour
+
+// SSLStores holds the type and passwords of the mTLS keystores, stored in .env
+type SSLStores struct {
+	Type               string // PKCS12 or JCEKS
+	KeystorePassword   string
+	TruststorePassword string
+	BrowserPassword    string // Password of keystores/client/browser.p12
+}
+
+// Env returns the .env entries read by compose.yaml for the mTLS keystores
+func (s SSLStores) Env() map[string]string {
+	return map[string]string{
+		"SSL_KEYSTORE_TYPE":       s.Type,
+		"SSL_KEYSTORE_PASSWORD":   s.KeystorePassword,
+		"SSL_TRUSTSTORE_TYPE":     s.Type,
+		"SSL_TRUSTSTORE_PASSWORD": s.TruststorePassword,
+		"SSL_BROWSER_PASSWORD":    s.BrowserPassword,
+	}
+}
+
+// newSSLStores returns PKCS12 stores protected with random passwords
+func newSSLStores() SSLStores {
+	return SSLStores{
+		Type:               "PKCS12",
+		KeystorePassword:   util.GenerateRandomString(24),
+		TruststorePassword: util.GenerateRandomString(24),
+		BrowserPassword:    util.GenerateRandomString(24),
+	}
+}
+
+// writeSSLKeystores issues a new CA with the repository, Solr and browser certificates
+// and writes them under dir/keystores with the aliases expected by compose.yaml:
+//
+//	alfresco/ssl.keystore             ssl.repo (key), ssl.alfresco.ca
+//	alfresco/ssl.truststore           alfresco.ca, ssl.repo.client
+//	solr/ssl-repo-client.keystore     ssl.repo.client (key), alfresco.ca
+//	solr/ssl-repo-client.truststore   ssl.repo, ssl.alfresco.ca, ssl.repo.client
+//	client/browser.p12                browser (key)
+func writeSSLKeystores(dir string, solrServices []string, stores SSLStores) error {
+	if stores.Type != "PKCS12" {
+		return fmt.Errorf("unsupported keystore type %s", stores.Type)
+	}
+
+	ca, err := util.NewCA("Alfresco CA", sslValidity)
+	if err != nil {
+		return err
+	}
+	repo, err := ca.Issue(util.CertRequest{
+		CommonName: "alfresco",
+		DNSNames:   []string{"alfresco", "localhost"},
+		Server:     true,
+		Client:     true,
+		Validity:   sslValidity,
+	})
+	if err != nil {
+		return err
+	}
+	solr, err := ca.Issue(util.CertRequest{
+		CommonName: "solr6",
+		DNSNames:   append(slices.Clone(solrServices), "localhost"),
+		Server:     true,
+		Client:     true,
+		Validity:   sslValidity,
+	})
+	if err != nil {
+		return err
+	}
+	browser, err := ca.Issue(util.CertRequest{CommonName: "browser", Client: true, Validity: sslValidity})
+	if err != nil {
+		return err
+	}
+
+	keyEntry := func(alias string, c *util.CertKey) util.KeystoreEntry {
+		return util.KeystoreEntry{Alias: alias, Key: c.Key, Chain: []*x509.Certificate{c.Cert, ca.Cert}}
+	}
+	trusted := func(alias string, cert *x509.Certificate) util.KeystoreEntry {
+		return util.KeystoreEntry{Alias: alias, Trusted: cert}
+	}
+
+	files := []struct {
+		path     string
+		password string
+		entries  []util.KeystoreEntry
+	}{
+		{"alfresco/ssl.keystore", stores.KeystorePassword, []util.KeystoreEntry{
+			keyEntry("ssl.repo", repo), trusted("ssl.alfresco.ca", ca.Cert)}},
+		{"alfresco/ssl.truststore", stores.TruststorePassword, []util.KeystoreEntry{
+			trusted("alfresco.ca", ca.Cert), trusted("ssl.repo.client", solr.Cert)}},
+		{"solr/ssl-repo-client.keystore", stores.KeystorePassword, []util.KeystoreEntry{
+			keyEntry("ssl.repo.client", solr), trusted("alfresco.ca", ca.Cert)}},
+		{"solr/ssl-repo-client.truststore", stores.TruststorePassword, []util.KeystoreEntry{
+			trusted("ssl.repo", repo.Cert), trusted("ssl.alfresco.ca", ca.Cert), trusted("ssl.repo.client", solr.Cert)}},
+		{"client/browser.p12", stores.BrowserPassword, []util.KeystoreEntry{
+			keyEntry("browser", browser)}},
+	}
+	for _, f := range files {
+		data, err := util.EncodePKCS12(f.entries, f.password)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", f.path, err)
+		}
+		path := filepath.Join(dir, "keystores", f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		// Readable by the container users; the keys are protected by the store passwords
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Metadata keystore, relative to the workspace, copied over the keystore of the repository image
+const metadataKeystoreFile = "alfresco/metadata-keystore/keystore"
+
+// MetadataKeystore holds the type and passwords of the keystore encrypting metadata properties, stored in .env
+type MetadataKeystore struct {
+	Type          string
+	StorePassword string
+	KeyPassword   string
+}
+
+// Env returns the .env entries read by compose.yaml for the metadata keystore
+func (m MetadataKeystore) Env() map[string]string {
+	return map[string]string{
+		"METADATA_KEYSTORE_TYPE":              m.Type,
+		"METADATA_KEYSTORE_PASSWORD":          m.StorePassword,
+		"METADATA_KEYSTORE_METADATA_PASSWORD": m.KeyPassword,
+	}
+}
+
+// newMetadataKeystore returns a PKCS12 metadata keystore protected with a random password.
+// The key uses the store password: keytool, used to rotate it, cannot write PKCS12 keys
+// with their own password.
+func newMetadataKeystore() MetadataKeystore {
+	password := util.GenerateRandomString(24)
+	return MetadataKeystore{Type: "PKCS12", StorePassword: password, KeyPassword: password}
+}
+
+// loadMetadataKeystore returns the passwords, read from .env, of the metadata keystore
+// generated before in dir, or a new keystore when there is none: the properties
+// already encrypted cannot be read with another key.
+func loadMetadataKeystore(dir string) (MetadataKeystore, error) {
+	if _, err := os.Stat(filepath.Join(dir, metadataKeystoreFile)); errors.Is(err, fs.ErrNotExist) {
+		return newMetadataKeystore(), nil
+	} else if err != nil {
+		return MetadataKeystore{}, err
+	}
+
+	env, err := util.ReadEnvFile(filepath.Join(dir, ".env"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return MetadataKeystore{}, err
+	}
+	ks := MetadataKeystore{
+		Type:          env["METADATA_KEYSTORE_TYPE"],
+		StorePassword: env["METADATA_KEYSTORE_PASSWORD"],
+		KeyPassword:   env["METADATA_KEYSTORE_METADATA_PASSWORD"],
+	}
+	if ks.Type == "" || ks.StorePassword == "" || ks.KeyPassword == "" {
+		return MetadataKeystore{}, fmt.Errorf("%s exists but .env has no METADATA_KEYSTORE_* passwords for it: restore them, or move the keystore away to create a new one", metadataKeystoreFile)
+	}
+	return ks, nil
+}
+
+// writeMetadataKeystore writes a keystore with a new DESede "metadata" key, unless
+// dir already has one
+func writeMetadataKeystore(dir string, ks MetadataKeystore) error {
+	path := filepath.Join(dir, metadataKeystoreFile)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	key := make([]byte, 24)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	// DES keys carry an odd parity bit in every byte
+	for i, b := range key {
+		b &= 0xfe
+		if bits.OnesCount8(b)%2 == 0 {
+			b |= 1
+		}
+		key[i] = b
+	}
+
+	data, err := util.EncodePKCS12([]util.KeystoreEntry{{Alias: "metadata", Secret: key, Password: ks.KeyPassword}}, ks.StorePassword)
+	if err != nil {
+		return fmt.Errorf("encode metadata keystore: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Only the owner reads it: the Dockerfile gives its copy in the image to the repository user
+	return os.WriteFile(path, data, 0o600)
+}
@@ -0,0 +1,51 @@
+package alfresco
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetadataKeystoreKeptOnRegeneration(t *testing.T) {
+	dir := t.TempDir()
+	first, err := loadMetadataKeystore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeMetadataKeystore(dir, first); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, metadataKeystoreFile)
+	key, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without its passwords the keystore is useless: generating again must fail
+	if _, err := loadMetadataKeystore(dir); err == nil {
+		t.Fatal("keystore loaded without .env")
+	}
+
+	var env strings.Builder
+	for k, v := range first.Env() {
+		env.WriteString(k + "=" + v + "\n")
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(env.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := loadMetadataKeystore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first {
+		t.Errorf("passwords not kept: got %+v, want %+v", second, first)
+	}
+	if err := writeMetadataKeystore(dir, second); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, key) {
+		t.Errorf("keystore rewritten (%v)", err)
+	}
+}
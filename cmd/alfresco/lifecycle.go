@@ -0,0 +1,163 @@
+package alfresco
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var lifecycleOptions struct {
+	Build  bool
+	Follow bool
+	Tail   string
+}
+
+var upCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Start the stack of a generated workspace",
+	Long: `Start the stack of a generated workspace in the background.
+On Linux, the ./data bind mounts are created with the ownership expected by each
+container first, as create_volumes.sh does.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ws, err := openLifecycleWorkspace()
+		if err != nil {
+			return err
+		}
+		if err := prepareVolumes(ws); err != nil {
+			return err
+		}
+		printStartupOrder(ws)
+
+		composeArgs := []string{"up", "-d"}
+		if lifecycleOptions.Build {
+			composeArgs = append(composeArgs, "--build")
+		}
+		return ws.compose(composeArgs...).Run()
+	},
+}
+
+var downCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Stop and remove the containers of a generated workspace (volumes are kept)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ws, err := openLifecycleWorkspace()
+		if err != nil {
+			return err
+		}
+		return ws.compose("down").Run()
+	},
+}
+
+var restartCmd = &cobra.Command{
+	Use:   "restart <service> [service...]",
+	Short: "Restart one or more services of a generated workspace",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ws, err := openLifecycleWorkspace()
+		if err != nil {
+			return err
+		}
+		for _, service := range args {
+			if !ws.Compose.HasService(service) {
+				return fmt.Errorf("unknown service %q, available services: %s", service, strings.Join(ws.Compose.Services(), ", "))
+			}
+		}
+		return ws.compose(append([]string{"restart"}, args...)...).Run()
+	},
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [service...]",
+	Short: "Show the logs of a generated workspace",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ws, err := openLifecycleWorkspace()
+		if err != nil {
+			return err
+		}
+		composeArgs := []string{"logs", "--tail", lifecycleOptions.Tail}
+		if lifecycleOptions.Follow {
+			composeArgs = append(composeArgs, "-f")
+		}
+		return ws.compose(append(composeArgs, args...)...).Run()
+	},
+}
+
+// openLifecycleWorkspace opens the workspace and reports the Compose flavour in use
+func openLifecycleWorkspace() (*Workspace, error) {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Using %s in %s\n", strings.Join(composeCommand(), " "), ws.Dir)
+	return ws, nil
+}
+
+// printStartupOrder prints the services grouped by dependency level
+func printStartupOrder(ws *Workspace) {
+	fmt.Println("Startup order:")
+	for i, group := range ws.Compose.StartupOrder() {
+		fmt.Printf("  %d. %s\n", i+1, strings.Join(group, ", "))
+	}
+}
+
+// prepareVolumes creates the ./data bind mounts with the ownership expected by the
+// containers. Docker Desktop maps ownership itself, so this only runs on Linux.
+func prepareVolumes(ws *Workspace) error {
+	if !util.IsLinux() || ws.UseDockerVolume() {
+		return nil
+	}
+
+	dataDir := filepath.Join(ws.Dir, "data")
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return fmt.Errorf("mkdir %s: %w", dataDir, err)
+	}
+
+	uid, gid := invokingUser()
+	fmt.Println("Preparing ./data volumes...")
+	if err := ws.runHelper([]string{dataDir + ":/data"}, prepareVolumesScript(ws.DataVolumes(), uid, gid)); err != nil {
+		return fmt.Errorf("prepare volumes: %w", err)
+	}
+	return nil
+}
+
+// prepareVolumesScript returns the commands of create_volumes.sh for ./data mounted at /data:
+// the folder belongs to the invoking user and every volume to the uid of its container.
+func prepareVolumesScript(volumes []string, uid, gid int) string {
+	script := []string{fmt.Sprintf("chown %d:%d /data", uid, gid)}
+	for _, v := range volumes {
+		script = append(script, "mkdir -p /data/"+v)
+		if chown := chownVolume(v, "/data/"+v); chown != "" {
+			script = append(script, chown)
+		}
+	}
+	return strings.Join(script, " && ")
+}
+
+// invokingUser returns the uid and gid of the user running alf, even behind sudo
+func invokingUser() (int, int) {
+	uid, gid := os.Getuid(), os.Getgid()
+	if id, err := strconv.Atoi(os.Getenv("SUDO_UID")); err == nil {
+		uid = id
+	}
+	if id, err := strconv.Atoi(os.Getenv("SUDO_GID")); err == nil {
+		gid = id
+	}
+	return uid, gid
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{upCmd, downCmd, restartCmd, logsCmd} {
+		addWorkspaceFlag(cmd)
+		rootCmd.AddCommand(cmd)
+	}
+	upCmd.Flags().BoolVar(&lifecycleOptions.Build, "build", false, "Build images before starting the containers")
+	logsCmd.Flags().BoolVarP(&lifecycleOptions.Follow, "follow", "f", false, "Follow log output")
+	logsCmd.Flags().StringVar(&lifecycleOptions.Tail, "tail", "all", "Number of lines to show from the end of the logs")
+}
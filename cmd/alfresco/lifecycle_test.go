@@ -0,0 +1,16 @@
+package alfresco
+
+import "testing"
+
+func TestPrepareVolumesScript(t *testing.T) {
+	volumes := []string{"alf-repo-data", "solr-data-1", "postgres-data", "activemq-data", "minio-data"}
+	want := "chown 1000:1000 /data" +
+		" && mkdir -p /data/alf-repo-data && chown -R 33000:33000 /data/alf-repo-data" +
+		" && mkdir -p /data/solr-data-1 && chown 33007:33007 /data/solr-data-1" +
+		" && mkdir -p /data/postgres-data && chown 999:999 /data/postgres-data" +
+		" && mkdir -p /data/activemq-data && chown -R 33031:33031 /data/activemq-data" +
+		" && mkdir -p /data/minio-data && chown 0:0 /data/minio-data"
+	if got := prepareVolumesScript(volumes, 1000, 1000); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
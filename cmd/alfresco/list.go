@@ -0,0 +1,89 @@
+package alfresco
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var listOptions struct {
+	Prune bool
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the stacks generated by alf-cli on this machine",
+	Args:  cobra.NoArgs,
+	RunE:  runList,
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	stacks, err := util.LoadStacks()
+	if err != nil {
+		return fmt.Errorf("read stack registry: %w", err)
+	}
+
+	if listOptions.Prune {
+		var kept []util.StackEntry
+		for _, s := range stacks {
+			if _, err := os.Stat(s.Dir); err == nil {
+				kept = append(kept, s)
+			}
+		}
+		if err := util.SaveStacks(kept); err != nil {
+			return err
+		}
+		stacks = kept
+	}
+
+	if len(stacks) == 0 {
+		fmt.Println("No stack registered, generate one with 'alf docker-compose'")
+		return nil
+	}
+
+	running := runningProjects()
+	fmt.Printf("%-20s %-8s %-9s %-12s %s\n", "NAME", "VERSION", "DATABASE", "STATUS", "FOLDER")
+	for _, s := range stacks {
+		status := running[s.Name]
+		if status == "" {
+			status = "stopped"
+		}
+		if _, err := os.Stat(s.Dir); err != nil {
+			status = "missing"
+		}
+		fmt.Printf("%-20s %-8s %-9s %-12s %s\n", s.Name, s.Version, s.Database, status, s.Dir)
+	}
+	return nil
+}
+
+// runningProjects returns the status of the Compose projects known by Docker ("running(9)", ...).
+// It is empty when Docker is not reachable or Compose v1 is used.
+func runningProjects() map[string]string {
+	projects := make(map[string]string)
+	out, err := exec.Command("docker", "compose", "ls", "--all", "--format", "json").Output()
+	if err != nil {
+		return projects
+	}
+	var entries []struct {
+		Name   string `json:"Name"`
+		Status string `json:"Status"`
+	}
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return projects
+	}
+	for _, e := range entries {
+		projects[e.Name] = strings.ReplaceAll(e.Status, ", ", ",")
+	}
+	return projects
+}
+
+func init() {
+	listCmd.Flags().BoolVar(&listOptions.Prune, "prune", false, "Remove stacks whose folder no longer exists from the registry")
+
+	rootCmd.AddCommand(listCmd)
+}
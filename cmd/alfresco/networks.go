@@ -0,0 +1,104 @@
+package alfresco
+
+import (
+	"slices"
+
+	"github.com/aborroy/alf-cli/internal/util"
+)
+
+// Networks of compose.yaml
+const (
+	networkFrontend  = "frontend"  // Proxy, web applications, repository and monitoring
+	networkBackend   = "backend"   // Database, content store and T-Engines
+	networkSearch    = "search"    // Repository and Search Services
+	networkMessaging = "messaging" // ActiveMQ and its clients
+)
+
+// serviceNetwork returns the network a service is reached on, or "" for the services
+// reached through the proxy or by the web applications
+func (c *Configuration) serviceNetwork(service string) string {
+	switch service {
+	case "postgres", "mariadb", "postgres-exporter", "mariadb-exporter", "minio", "minio-init", "transform-ocr":
+		return networkBackend
+	case "activemq":
+		return networkMessaging
+	}
+	for _, engine := range c.TransformEngines() {
+		if service == engine.Name {
+			return networkBackend
+		}
+	}
+	for _, solr := range c.SolrInstances() {
+		if service == solr.Name {
+			return networkSearch
+		}
+	}
+	return ""
+}
+
+// networkPeers returns the services a service reaches without waiting for them in depends_on:
+// the repository sends OCR transforms to transform-ocr and Prometheus scrapes the targets
+// of prometheus.yml.
+func (c *Configuration) networkPeers(service string) []string {
+	switch service {
+	case "alfresco":
+		return []string{"transform-ocr"}
+	case "prometheus":
+		peers := []string{"alfresco", "transform-ocr", c.Database + "-exporter", "activemq"}
+		for _, engine := range c.TransformEngines() {
+			peers = append(peers, engine.Name)
+		}
+		return peers
+	}
+	return nil
+}
+
+// serviceNetworks attaches the services of a compose file to the networks of their
+// depends_on entries and network peers: both ends of a dependency join the network of the
+// service depended on, or of the dependent service when the former is reached on frontend
+// (e.g. Solr depending on the repository), so the web applications cannot reach the
+// database and the proxy cannot reach Solr.
+func (c *Configuration) serviceNetworks(compose *util.ComposeFile) map[string][]string {
+	networks := make(map[string][]string)
+	join := func(service, network string) {
+		if !slices.Contains(networks[service], network) {
+			networks[service] = append(networks[service], network)
+		}
+	}
+	for _, service := range compose.Services() {
+		dependencies := compose.DependsOn(service)
+		for _, peer := range c.networkPeers(service) {
+			if compose.HasService(peer) && !slices.Contains(dependencies, peer) {
+				dependencies = append(dependencies, peer)
+			}
+		}
+		for _, dependency := range dependencies {
+			network := c.serviceNetwork(dependency)
+			if network == "" {
+				network = c.serviceNetwork(service)
+			}
+			if network == "" {
+				network = networkFrontend
+			}
+			join(service, network)
+			join(dependency, network)
+		}
+	}
+	for service, list := range networks {
+		slices.Sort(list)
+		networks[service] = list
+	}
+	return networks
+}
+
+// Networks returns the networks used by the services, with whether they are internal:
+// with --hardened, only frontend can reach or be reached from outside the host
+func (c *Configuration) Networks() map[string]bool {
+	networks := make(map[string]bool)
+	for _, list := range c.ServiceNetworks {
+		for _, network := range list {
+			networks[network] = c.Hardened && network != networkFrontend
+		}
+	}
+	return networks
+}
@@ -0,0 +1,69 @@
+package alfresco
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aborroy/alf-cli/internal/util"
+)
+
+func TestServiceNetworks(t *testing.T) {
+	const compose = `services:
+  postgres: {}
+  postgres-exporter:
+    depends_on: [postgres]
+  transform-core-aio: {}
+  transform-ocr: {}
+  activemq: {}
+  alfresco:
+    depends_on: [postgres, transform-core-aio, activemq]
+  solr6:
+    depends_on: [alfresco]
+  share:
+    depends_on: [alfresco]
+  proxy:
+    depends_on: [alfresco, share]
+  prometheus: {}
+  grafana:
+    depends_on: [prometheus]
+`
+	file, err := util.ParseComposeFile("compose.yaml", []byte(compose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Configuration{Database: "postgres", TransformMode: "aio", UseActiveMQ: true}
+	networks := config.serviceNetworks(file)
+
+	want := map[string][]string{
+		"postgres":           {networkBackend},
+		"postgres-exporter":  {networkBackend},
+		"transform-core-aio": {networkBackend},
+		"transform-ocr":      {networkBackend}, // Peer of the repository and Prometheus
+		"activemq":           {networkMessaging},
+		"alfresco":           {networkBackend, networkFrontend, networkMessaging, networkSearch},
+		"solr6":              {networkSearch},
+		"share":              {networkFrontend},
+		"proxy":              {networkFrontend},
+		"prometheus":         {networkBackend, networkFrontend, networkMessaging},
+		"grafana":            {networkFrontend},
+	}
+	for service, list := range want {
+		if !slices.Equal(networks[service], list) {
+			t.Errorf("%s: got %v, want %v", service, networks[service], list)
+		}
+	}
+	if len(networks) != len(want) {
+		t.Errorf("got networks for %d services, want %d", len(networks), len(want))
+	}
+}
+
+func TestNetworksHardened(t *testing.T) {
+	config := Configuration{Hardened: true, ServiceNetworks: map[string][]string{
+		"proxy":    {networkFrontend},
+		"alfresco": {networkBackend, networkFrontend},
+	}}
+	got := config.Networks()
+	if len(got) != 2 || got[networkFrontend] || !got[networkBackend] {
+		t.Errorf("got %v, want an external frontend and an internal backend", got)
+	}
+}
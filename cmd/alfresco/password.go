@@ -0,0 +1,199 @@
+package alfresco
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/aborroy/alf-cli/ui/selector"
+	"github.com/spf13/cobra"
+)
+
+// Namespace of the user store model holding the password properties
+const userModelURI = "http://www.alfresco.org/model/user/1.0"
+
+var passwordOptions struct {
+	User        string
+	OldPassword string
+	NewPassword string
+}
+
+var passwordCmd = &cobra.Command{
+	Use:   "password",
+	Short: "Manage user passwords of a running stack",
+}
+
+var passwordResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Reset the password of a repository user",
+	Long: `Reset the password of a repository user on a running stack.
+When the current password is known, the change goes through the REST API. Otherwise the
+NT hash of the new password is written to alf_node_properties through the database
+container and the repository is restarted to drop its caches.`,
+	Args: cobra.NoArgs,
+	RunE: runPasswordReset,
+}
+
+func runPasswordReset(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+	cmdFlags := cmd.Flags()
+
+	newPassword := passwordOptions.NewPassword
+	if !cmdFlags.Changed("new-password") {
+		newPassword, err = selector.RunPasswordInput(fmt.Sprintf("Choose the new password for '%s'", passwordOptions.User), "")
+		if err != nil {
+			return err
+		}
+	}
+	if newPassword == "" {
+		return fmt.Errorf("the new password cannot be empty")
+	}
+
+	oldPassword := passwordOptions.OldPassword
+	if !cmdFlags.Changed("old-password") && !cmdFlags.Changed("new-password") {
+		known, err := selector.RunYesNoSelector(fmt.Sprintf("Do you know the current password of '%s'?", passwordOptions.User), true)
+		if err != nil {
+			return err
+		}
+		if known {
+			if oldPassword, err = selector.RunPasswordInput("Current password", ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	if oldPassword != "" {
+		err = changePasswordREST(ws, passwordOptions.User, oldPassword, newPassword)
+	} else {
+		err = resetPasswordDB(ws, passwordOptions.User, newPassword)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Keep the initial admin hash in line, it is used again if the database is reset
+	if passwordOptions.User == "admin" {
+		if err := ws.UpdateSecrets(map[string]string{
+			"ADMIN_PASSWORD": util.ComputeHashPassword(newPassword),
+		}); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Password of '%s' updated\n", passwordOptions.User)
+	return nil
+}
+
+// changePasswordREST updates the password with the People API, authenticated as the user
+func changePasswordREST(ws *Workspace, user, oldPassword, newPassword string) error {
+	body, err := json.Marshal(map[string]string{"oldPassword": oldPassword, "password": newPassword})
+	if err != nil {
+		return err
+	}
+	endpoint := ws.PublicURL() + "/alfresco/api/-default-/public/alfresco/versions/1/people/" + url.PathEscape(user)
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Host = ws.Env["SERVER_NAME"]
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(user, oldPassword)
+
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("repository not reachable at %s: %w", ws.PublicURL(), err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("the current password of '%s' is not valid", user)
+	default:
+		return fmt.Errorf("password change failed: HTTP %d", resp.StatusCode)
+	}
+}
+
+// resetPasswordDB writes the NT hash of the new password to the user store node and
+// drops the hash indicator, so the repository falls back to MD4 and rehashes the
+// password on the next login.
+func resetPasswordDB(ws *Workspace, user, newPassword string) error {
+	userNodes := fmt.Sprintf(`SELECT node_id FROM (
+  SELECT p.node_id FROM alf_node_properties p
+  JOIN alf_qname q ON q.id = p.qname_id
+  JOIN alf_namespace ns ON ns.id = q.ns_id
+  WHERE ns.uri = '%s' AND q.local_name = 'username' AND p.string_value = '%s'
+) AS u`, userModelURI, sqlEscape(ws.Database(), user))
+	qnames := func(names string) string {
+		return fmt.Sprintf(`SELECT q.id FROM alf_qname q JOIN alf_namespace ns ON ns.id = q.ns_id WHERE ns.uri = '%s' AND q.local_name IN (%s)`, userModelURI, names)
+	}
+
+	count, err := runSQL(ws, "SELECT COUNT(*) FROM ("+userNodes+") AS c;")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(count) != "1" {
+		return fmt.Errorf("user '%s' not found in the repository user store", user)
+	}
+
+	sql := fmt.Sprintf(`UPDATE alf_node_properties SET string_value = '%s'
+WHERE node_id IN (%s) AND qname_id IN (%s);
+DELETE FROM alf_node_properties
+WHERE node_id IN (%s) AND qname_id IN (%s);
+`, util.ComputeHashPassword(newPassword), userNodes, qnames("'password', 'passwordHash'"), userNodes, qnames("'hashIndicator', 'salt'"))
+	if _, err := runSQL(ws, sql); err != nil {
+		return err
+	}
+
+	fmt.Println("Restarting the repository to clear its caches...")
+	return ws.compose("restart", "alfresco").Run()
+}
+
+// runSQL executes SQL statements in the database container and returns the unformatted result
+func runSQL(ws *Workspace, sql string) (string, error) {
+	args := []string{"exec", "-T", "postgres", "psql", "-U", ws.DbUser(), "-d", ws.DbName(), "-tA", "-q", "-v", "ON_ERROR_STOP=1"}
+	if ws.Database() == "mariadb" {
+		args = []string{"exec", "-T", "mariadb", "sh", "-c", `mariadb -N -u"$MYSQL_USER" -p` + mariadbPassword + ` "$MYSQL_DATABASE"`}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := ws.compose(args...)
+	cmd.Stdin = strings.NewReader(sql)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s query failed (is the stack running?): %w: %s", ws.Database(), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// sqlEscape doubles single quotes in a value used in a SQL string literal of the
+// database, and backslashes for MariaDB, which reads them as escape characters
+func sqlEscape(database, s string) string {
+	if database == "mariadb" {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+func init() {
+	addWorkspaceFlag(passwordResetCmd)
+	passwordResetCmd.Flags().StringVar(&passwordOptions.User, "user", "admin", "User whose password is reset")
+	passwordResetCmd.Flags().StringVar(&passwordOptions.OldPassword, "old-password", "", "Current password, when known (uses the REST API)")
+	passwordResetCmd.Flags().StringVar(&passwordOptions.NewPassword, "new-password", "", "New password")
+
+	passwordCmd.AddCommand(passwordResetCmd)
+	rootCmd.AddCommand(passwordCmd)
+}
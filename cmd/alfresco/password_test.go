@@ -0,0 +1,21 @@
+package alfresco
+
+import "testing"
+
+func TestSQLEscape(t *testing.T) {
+	tests := []struct {
+		database, value, want string
+	}{
+		{"postgres", "admin", "admin"},
+		{"postgres", "o'brien", "o''brien"},
+		{"postgres", `a\'b`, `a\''b`},
+		{"mariadb", "o'brien", "o''brien"},
+		{"mariadb", `a\'b`, `a\\''b`},
+		{"mariadb", `x\' OR 1=1 -- `, `x\\'' OR 1=1 -- `},
+	}
+	for _, tt := range tests {
+		if got := sqlEscape(tt.database, tt.value); got != tt.want {
+			t.Errorf("sqlEscape(%s, %q) = %q, want %q", tt.database, tt.value, got, tt.want)
+		}
+	}
+}
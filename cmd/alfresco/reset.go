@@ -0,0 +1,128 @@
+package alfresco
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/aborroy/alf-cli/ui/selector"
+	"github.com/spf13/cobra"
+)
+
+var resetOptions struct {
+	KeepDB      bool
+	KeepContent bool
+	KeepIndex   bool
+	Yes         bool
+}
+
+var resetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Wipe the data of a generated workspace (database, content store, Solr indexes)",
+	Long: `Stop the stack and wipe its data. Docker named volumes are removed and created again
+by Compose on the next start; ./data bind mounts are emptied and given back the ownership
+set by create_volumes.sh. Use the --keep-* flags to preserve part of the data, e.g.
+"alf reset --keep-db --keep-content" rebuilds the Solr indexes only.`,
+	Args: cobra.NoArgs,
+	RunE: runReset,
+}
+
+func runReset(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+
+	volumes := resetVolumes(ws)
+	if len(volumes) == 0 {
+		fmt.Println("Nothing to reset")
+		return nil
+	}
+
+	fmt.Println("The following data will be deleted:")
+	for _, v := range volumes {
+		kind := "folder"
+		if ws.UseDockerVolume() {
+			kind = "volume"
+		}
+		fmt.Printf("  - %s %s\n", kind, ws.VolumeSource(v))
+	}
+	if !resetOptions.Yes {
+		confirm, err := selector.RunYesNoSelector("Stop the stack and delete this data?", false)
+		if err != nil {
+			return err
+		}
+		if !confirm {
+			return nil
+		}
+	}
+
+	fmt.Println("Stopping the stack...")
+	if err := ws.compose("down").Run(); err != nil {
+		return fmt.Errorf("docker compose down: %w", err)
+	}
+	for _, v := range volumes {
+		fmt.Printf("Resetting %s...\n", v)
+		if err := resetVolume(ws, v); err != nil {
+			return fmt.Errorf("reset %s: %w", v, err)
+		}
+	}
+
+	fmt.Println("Reset completed, start the stack with \"alf up\"")
+	return nil
+}
+
+// resetVolumes returns the data volumes wiped by the reset, honouring the --keep-* flags.
+// Volumes outside the three categories (ActiveMQ, Prometheus) are only wiped by a full reset.
+func resetVolumes(ws *Workspace) []string {
+	full := !resetOptions.KeepDB && !resetOptions.KeepContent && !resetOptions.KeepIndex
+	solr := ws.SolrVolumes()
+
+	var volumes []string
+	for _, v := range ws.DataVolumes() {
+		var keep bool
+		switch {
+		case v == "postgres-data" || v == "mariadb-data":
+			keep = resetOptions.KeepDB
+		case v == "alf-repo-data" || v == "minio-data":
+			keep = resetOptions.KeepContent
+		case slices.Contains(solr, v):
+			keep = resetOptions.KeepIndex
+		default:
+			keep = !full
+		}
+		if !keep {
+			volumes = append(volumes, v)
+		}
+	}
+	return volumes
+}
+
+// resetVolume removes a named volume, so Compose creates it again from the image on
+// the next start, or empties a ./data bind mount and restores its ownership.
+func resetVolume(ws *Workspace, name string) error {
+	if ws.UseDockerVolume() {
+		out, err := exec.Command("docker", "volume", "rm", "--force", ws.VolumeSource(name)).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	script := "find /target -mindepth 1 -delete"
+	if chown := chownVolume(name, "/target"); chown != "" {
+		script += " && " + chown
+	}
+	return ws.runHelper([]string{ws.VolumeSource(name) + ":/target"}, script)
+}
+
+func init() {
+	addWorkspaceFlag(resetCmd)
+	resetCmd.Flags().BoolVar(&resetOptions.KeepDB, "keep-db", false, "Keep the database")
+	resetCmd.Flags().BoolVar(&resetOptions.KeepContent, "keep-content", false, "Keep the content store")
+	resetCmd.Flags().BoolVar(&resetOptions.KeepIndex, "keep-index", false, "Keep the Solr indexes")
+	resetCmd.Flags().BoolVarP(&resetOptions.Yes, "yes", "y", false, "Do not ask for confirmation")
+
+	rootCmd.AddCommand(resetCmd)
+}
@@ -0,0 +1,211 @@
+package alfresco
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var restoreOptions struct {
+	DropIndex bool
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <archive.tar.gz>",
+	Short: "Restore a snapshot or backup into a generated workspace",
+	Long: `Restore an archive created by "alf snapshot" or "alf backup".
+The stack is stopped, the database dump is loaded into the database service and the
+content store is copied back into its Docker volume or ./data bind mount with the
+ownership expected by the containers. The archive must come from the same ACS version
+and database engine as the workspace.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestore,
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+	archive, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+
+	staging, err := os.MkdirTemp(ws.Dir, ".alf-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	if err := util.ExtractArchive(archive, staging); err != nil {
+		return err
+	}
+	manifest, err := readBackupManifest(staging)
+	if err != nil {
+		return err
+	}
+	if err := checkRestoreCompatibility(manifest, ws.Configuration()); err != nil {
+		return err
+	}
+
+	fmt.Println("Stopping the stack...")
+	if err := ws.compose("down").Run(); err != nil {
+		return fmt.Errorf("docker compose down: %w", err)
+	}
+
+	for _, item := range manifest.Items {
+		switch item.Kind {
+		case "database":
+			if err := restoreDatabase(ws, filepath.Join(staging, item.File)); err != nil {
+				return err
+			}
+		case "content", "index":
+			if item.Kind == "index" && restoreOptions.DropIndex {
+				continue
+			}
+			fmt.Printf("Restoring %s...\n", item.Name)
+			if err := restoreVolume(ws, item.Name, staging, item.File); err != nil {
+				return fmt.Errorf("restore %s: %w", item.Name, err)
+			}
+		}
+	}
+
+	if restoreOptions.DropIndex {
+		for _, v := range ws.SolrVolumes() {
+			fmt.Printf("Dropping Solr index %s...\n", v)
+			if err := resetVolume(ws, v); err != nil {
+				return fmt.Errorf("drop %s: %w", v, err)
+			}
+		}
+	}
+
+	fmt.Printf("Restored %s taken on %s\n", filepath.Base(archive), manifest.Created.Local().Format(time.RFC1123))
+	fmt.Println("Start the stack with \"alf up\"")
+	return nil
+}
+
+// readBackupManifest loads the manifest of an extracted backup archive
+func readBackupManifest(dir string) (*BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, backupManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("not an alf-cli archive: %w", err)
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", backupManifestFile, err)
+	}
+	return &manifest, nil
+}
+
+// checkRestoreCompatibility refuses archives taken from another ACS version, database engine
+// or content store
+func checkRestoreCompatibility(manifest *BackupManifest, config *Configuration) error {
+	if manifest.Version != config.Version {
+		return fmt.Errorf("archive was taken from ACS %s but the workspace runs ACS %s", manifest.Version, config.Version)
+	}
+	if manifest.Database != config.Database {
+		return fmt.Errorf("archive holds a %s dump but the workspace uses %s", manifest.Database, config.Database)
+	}
+	hasMinio := slices.ContainsFunc(manifest.Items, func(item BackupItem) bool { return item.Name == "minio-data" })
+	if hasMinio != (config.ContentStore == "s3") {
+		if hasMinio {
+			return fmt.Errorf("archive holds the MinIO content store but the workspace stores content on the filesystem")
+		}
+		return fmt.Errorf("archive does not hold the MinIO content store used by the workspace")
+	}
+	return nil
+}
+
+// restoreDatabase starts the database service alone and loads the SQL dump into it
+func restoreDatabase(ws *Workspace, dumpPath string) error {
+	db := ws.Database()
+	fmt.Printf("Restoring %s database...\n", db)
+	if err := ws.compose("up", "-d", db).Run(); err != nil {
+		return fmt.Errorf("start %s: %w", db, err)
+	}
+	defer ws.compose("stop", db).Run()
+
+	ready := []string{"exec", "-T", db, "pg_isready", "-U", ws.DbUser(), "-d", ws.DbName()}
+	load := []string{"exec", "-T", db, "psql", "-q", "-v", "ON_ERROR_STOP=1", "-U", ws.DbUser(), "-d", ws.DbName()}
+	if db == "mariadb" {
+		ready = []string{"exec", "-T", db, "sh", "-c", `mariadb-admin ping -u"$MYSQL_USER" -p` + mariadbPassword}
+		load = []string{"exec", "-T", db, "sh", "-c", `mariadb -u"$MYSQL_USER" -p` + mariadbPassword + ` "$MYSQL_DATABASE"`}
+	}
+	if err := waitFor(ws, ready, 2*time.Minute); err != nil {
+		return fmt.Errorf("%s is not ready: %w", db, err)
+	}
+
+	dump, err := os.Open(dumpPath)
+	if err != nil {
+		return err
+	}
+	defer dump.Close()
+
+	cmd := ws.compose(load...)
+	cmd.Stdin = dump
+	cmd.Stdout = nil
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("load %s dump: %w", db, err)
+	}
+	return nil
+}
+
+// waitFor runs a compose command until it succeeds or the timeout expires
+func waitFor(ws *Workspace, args []string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		cmd := ws.compose(args...)
+		cmd.Stdout, cmd.Stderr = nil, nil
+		err := cmd.Run()
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(*exec.ExitError); !ok || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// restoreVolume empties a data volume, expands the tar file into it and fixes its ownership.
+func restoreVolume(ws *Workspace, name, staging, file string) error {
+	if ws.UseDockerVolume() {
+		if err := createVolume(ws, name); err != nil {
+			return err
+		}
+	}
+	script := fmt.Sprintf("find /target -mindepth 1 -delete && tar -xf /backup/%s -C /target", file)
+	if owner := volumeOwner(name); owner != "" {
+		script += fmt.Sprintf(" && chown -R %s /target", owner)
+	}
+	return ws.runHelper([]string{ws.VolumeSource(name) + ":/target", staging + ":/backup:ro"}, script)
+}
+
+// createVolume creates a named volume with the labels Docker Compose sets, so it does not
+// report the volume as created outside the project. An existing volume is left untouched.
+func createVolume(ws *Workspace, name string) error {
+	out, err := exec.Command("docker", "volume", "create",
+		"--label", "com.docker.compose.project="+ws.ProjectName(),
+		"--label", "com.docker.compose.volume="+name,
+		ws.VolumeSource(name)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func init() {
+	addWorkspaceFlag(restoreCmd)
+	restoreCmd.Flags().BoolVar(&restoreOptions.DropIndex, "drop-index", true, "Empty the Solr indexes so they are rebuilt from the restored repository")
+
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -0,0 +1,30 @@
+package alfresco
+
+import "testing"
+
+func TestCheckRestoreCompatibility(t *testing.T) {
+	content := []BackupItem{{Kind: "database", Name: "alfresco"}, {Kind: "content", Name: "alf-repo-data"}}
+	withMinio := append(content[:2:2], BackupItem{Kind: "content", Name: "minio-data"})
+	workspace := Configuration{Version: "25.2", Database: "postgres", ContentStore: "filesystem"}
+	s3 := Configuration{Version: "25.2", Database: "postgres", ContentStore: "s3"}
+
+	tests := []struct {
+		name     string
+		manifest BackupManifest
+		config   Configuration
+		ok       bool
+	}{
+		{"same settings", BackupManifest{Version: "25.2", Database: "postgres", Items: content}, workspace, true},
+		{"other version", BackupManifest{Version: "25.1", Database: "postgres", Items: content}, workspace, false},
+		{"other database", BackupManifest{Version: "25.2", Database: "mariadb", Items: content}, workspace, false},
+		{"s3 archive and workspace", BackupManifest{Version: "25.2", Database: "postgres", Items: withMinio}, s3, true},
+		{"s3 workspace, archive without minio-data", BackupManifest{Version: "25.2", Database: "postgres", Items: content}, s3, false},
+		{"filesystem workspace, archive with minio-data", BackupManifest{Version: "25.2", Database: "postgres", Items: withMinio}, workspace, false},
+	}
+	for _, tt := range tests {
+		err := checkRestoreCompatibility(&tt.manifest, &tt.config)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
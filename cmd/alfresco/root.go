@@ -1,14 +1,15 @@
 package alfresco
 
 import (
-	"embed"
 	"fmt"
+	"io/fs"
 	"os"
 
 	"github.com/spf13/cobra"
 )
 
-var TemplateFS embed.FS
+// TemplateFS holds the templates/ folder, embedded by main
+var TemplateFS fs.FS
 
 var rootCmd = &cobra.Command{
 	Use:   "alfresco",
@@ -0,0 +1,259 @@
+package alfresco
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/spf13/cobra"
+)
+
+// Length of the passwords generated when rotating secrets
+const rotatedPasswordLength = 24
+
+// Location of the metadata keystore in the repository image
+const metadataKeystorePath = "/usr/local/tomcat/shared/classes/alfresco/extension/keystore/keystore"
+
+// secretRotation holds the new .env values of a rotated secret
+type secretRotation struct {
+	Env   map[string]string
+	Build bool         // Values are image build arguments or files copied into images
+	After func() error // Runs once .env is updated, before the services are recreated
+}
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the secrets of a generated workspace",
+}
+
+var secretsRotateCmd = &cobra.Command{
+	Use:   "rotate [solr|db|amq|metadata]",
+	Short: "Generate new secrets and recreate the services using them",
+	Long: `Generate new values for a group of secrets, update .env (or the Docker secrets under
+secrets/) and recreate the services referencing them, following the depends_on order.
+
+  solr      SECURE_COMMS_SECRET, or new mTLS keystores and passwords with https comms
+  db        Database password (changed in the running database first)
+  amq       ActiveMQ admin password
+  metadata  Passwords of the metadata keystore (the encryption key is kept)`,
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: []string{"solr", "db", "amq", "metadata"},
+	RunE:      runSecretsRotate,
+}
+
+func runSecretsRotate(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+
+	var rotation *secretRotation
+	switch args[0] {
+	case "solr":
+		rotation, err = rotateSolrSecrets(ws)
+	case "db":
+		rotation, err = rotateDatabasePassword(ws)
+	case "amq":
+		rotation, err = rotateActiveMQPassword(ws)
+	case "metadata":
+		rotation, err = rotateMetadataKeystore(ws)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := ws.UpdateSecrets(rotation.Env); err != nil {
+		return fmt.Errorf("update secrets: %w", err)
+	}
+	for _, key := range slices.Sorted(maps.Keys(rotation.Env)) {
+		fmt.Printf("Updated %s\n", key)
+	}
+	if rotation.After != nil {
+		if err := rotation.After(); err != nil {
+			return err
+		}
+	}
+
+	var services []string
+	for key := range rotation.Env {
+		for _, service := range ws.ServicesUsingSecret(key) {
+			if !slices.Contains(services, service) {
+				services = append(services, service)
+			}
+		}
+	}
+	return recreateServices(ws, services, rotation.Build)
+}
+
+// rotateSolrSecrets replaces the shared secret, or the mTLS keystores, used between the repository and Solr
+func rotateSolrSecrets(ws *Workspace) (*secretRotation, error) {
+	switch ws.SolrComm() {
+	case "secret":
+		return &secretRotation{Env: map[string]string{"SECURE_COMMS_SECRET": util.GenerateRandomString(32)}}, nil
+	case "https":
+		if _, ok := ws.Env["SSL_KEYSTORE_PASSWORD"]; !ok {
+			return nil, fmt.Errorf("keystore passwords are hardcoded in this workspace compose.yaml; generate it again to rotate them")
+		}
+		stores := newSSLStores()
+		fmt.Println("Issuing new mTLS keystores...")
+		if err := writeSSLKeystores(ws.Dir, ws.SolrServices(), stores); err != nil {
+			return nil, fmt.Errorf("write keystores: %w", err)
+		}
+		// Keystore passwords are build arguments of both images
+		return &secretRotation{Env: stores.Env(), Build: true}, nil
+	default:
+		return nil, fmt.Errorf("no secret to rotate: Solr communication is %q", ws.SolrComm())
+	}
+}
+
+// rotateDatabasePassword changes the password in the running database before it is written to .env
+func rotateDatabasePassword(ws *Workspace) (*secretRotation, error) {
+	password := util.GenerateRandomString(rotatedPasswordLength)
+	literal := "'" + sqlEscape(ws.Database(), password) + "'"
+
+	fmt.Printf("Changing the %s password...\n", ws.Database())
+	if ws.Database() == "mariadb" {
+		// The root password is also set from DB_PASSWORD
+		sql := fmt.Sprintf("ALTER USER IF EXISTS '%[2]s'@'%%' IDENTIFIED BY %[1]s;\n"+
+			"ALTER USER IF EXISTS 'root'@'%%' IDENTIFIED BY %[1]s;\n"+
+			"ALTER USER IF EXISTS 'root'@'localhost' IDENTIFIED BY %[1]s;\n"+
+			"FLUSH PRIVILEGES;\n", literal, sqlEscape(ws.Database(), ws.DbUser()))
+		var stderr bytes.Buffer
+		cmd := ws.compose("exec", "-T", "mariadb", "sh", "-c", `mariadb -u root -p`+mariadbRootPassword)
+		cmd.Stdin = strings.NewReader(sql)
+		cmd.Stdout, cmd.Stderr = nil, &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("mariadb query failed (is the stack running?): %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	} else if _, err := runSQL(ws, fmt.Sprintf("ALTER USER \"%s\" WITH PASSWORD %s;\n", ws.DbUser(), literal)); err != nil {
+		return nil, err
+	}
+	return &secretRotation{Env: map[string]string{"DB_PASSWORD": password}}, nil
+}
+
+// rotateActiveMQPassword replaces the password of the ActiveMQ admin user, set by the image on startup
+func rotateActiveMQPassword(ws *Workspace) (*secretRotation, error) {
+	if !ws.Compose.HasService("activemq") {
+		return nil, fmt.Errorf("this workspace has no activemq service")
+	}
+	return &secretRotation{Env: map[string]string{"ACTIVEMQ_ADMIN_PASSWORD": util.GenerateRandomString(rotatedPasswordLength)}}, nil
+}
+
+// rotateMetadataKeystore copies the metadata key to a keystore protected with new passwords.
+// keytool runs in a one-off repository container and writes alfresco/metadata-keystore/keystore,
+// which the Dockerfile copies over the keystore of the image.
+func rotateMetadataKeystore(ws *Workspace) (*secretRotation, error) {
+	outDir := filepath.Join(ws.Dir, "alfresco", "metadata-keystore")
+	dockerfile, err := os.ReadFile(filepath.Join(ws.Dir, "alfresco", "Dockerfile"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(string(dockerfile), "metadata-keystore/") {
+		return nil, fmt.Errorf("alfresco/Dockerfile does not copy alfresco/metadata-keystore; generate the workspace again to rotate the metadata keystore")
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// Workspaces generated before the per-project keystore use the JCEKS one of the image
+	rotated := MetadataKeystore{Type: ws.Env["METADATA_KEYSTORE_TYPE"], StorePassword: util.GenerateRandomString(rotatedPasswordLength)}
+	if rotated.Type == "" {
+		rotated.Type = "JCEKS"
+	}
+	rotated.KeyPassword = rotated.StorePassword
+	if rotated.Type == "JCEKS" {
+		rotated.KeyPassword = util.GenerateRandomString(rotatedPasswordLength)
+	}
+
+	script := `set -e
+ks=` + metadataKeystorePath + `
+[ -f /out/keystore ] && ks=/out/keystore
+rm -f /out/keystore.new
+keytool -importkeystore -noprompt \
+  -srckeystore "$ks" -srcstoretype "$STORE_TYPE" -srcstorepass "$OLD_STORE_PASSWORD" \
+  -srcalias metadata -srckeypass "$OLD_KEY_PASSWORD" \
+  -destkeystore /out/keystore.new -deststoretype "$STORE_TYPE" -deststorepass "$NEW_STORE_PASSWORD" \
+  -destalias metadata -destkeypass "$NEW_KEY_PASSWORD"
+chown "$(stat -c %u:%g /out)" /out/keystore.new
+chmod 600 /out/keystore.new`
+
+	fmt.Println("Re-encrypting the metadata keystore...")
+	args := []string{"run", "--rm", "--no-deps", "-T", "--user", "root", "--entrypoint", "sh",
+		"-v", outDir + ":/out",
+		"-e", "STORE_TYPE", "-e", "OLD_STORE_PASSWORD", "-e", "OLD_KEY_PASSWORD", "-e", "NEW_STORE_PASSWORD", "-e", "NEW_KEY_PASSWORD"}
+	if len(ws.Compose.Root.Strings("services", "alfresco", "cap_drop")) > 0 {
+		// Workspaces generated with --hardened drop the capabilities root needs to write to /out
+		args = append(args, "--cap-add", "CHOWN", "--cap-add", "DAC_OVERRIDE")
+	}
+	var stderr bytes.Buffer
+	cmd := ws.compose(append(args, "alfresco", "-c", script)...)
+	cmd.Env = append(os.Environ(),
+		"STORE_TYPE="+rotated.Type,
+		"OLD_STORE_PASSWORD="+ws.Env["METADATA_KEYSTORE_PASSWORD"],
+		"OLD_KEY_PASSWORD="+ws.Env["METADATA_KEYSTORE_METADATA_PASSWORD"],
+		"NEW_STORE_PASSWORD="+rotated.StorePassword,
+		"NEW_KEY_PASSWORD="+rotated.KeyPassword,
+	)
+	cmd.Stdout, cmd.Stderr = nil, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("keytool failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return &secretRotation{
+		Env:   rotated.Env(),
+		Build: true,
+		After: func() error {
+			return os.Rename(filepath.Join(outDir, "keystore.new"), filepath.Join(outDir, "keystore"))
+		},
+	}, nil
+}
+
+// recreateServices recreates the services group by group, in startup order, so they
+// read the new .env values. Nothing is started when the stack is down.
+func recreateServices(ws *Workspace, services []string, build bool) error {
+	running, err := ws.RunningServices()
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(services, func(s string) bool { return slices.Contains(running, s) }) {
+		fmt.Println("The stack is not running: the new secrets are used on the next 'alf up'.")
+		return nil
+	}
+
+	args := []string{"up", "-d", "--no-deps", "--force-recreate"}
+	if build {
+		args = append(args, "--build")
+	}
+	if len(composeCommand()) > 1 {
+		// Compose v2 only: wait for the health checks before the next group
+		args = append(args, "--wait")
+	}
+	for _, group := range ws.Compose.StartupOrder() {
+		var batch []string
+		for _, service := range group {
+			if slices.Contains(services, service) {
+				batch = append(batch, service)
+			}
+		}
+		if len(batch) == 0 {
+			continue
+		}
+		fmt.Printf("Recreating %s...\n", strings.Join(batch, ", "))
+		if err := ws.compose(append(slices.Clone(args), batch...)...).Run(); err != nil {
+			return fmt.Errorf("recreate %s: %w", strings.Join(batch, ", "), err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	addWorkspaceFlag(secretsRotateCmd)
+
+	secretsCmd.AddCommand(secretsRotateCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
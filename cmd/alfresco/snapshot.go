@@ -0,0 +1,41 @@
+package alfresco
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var snapshotOptions struct {
+	Output string
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Take a snapshot (database dump and content store) of a generated workspace",
+	Long: `Take a snapshot of a generated workspace that can be brought back with "alf restore".
+The snapshot holds a dump of the database and the content store; Solr indexes are
+not included and are rebuilt after a restore.`,
+	RunE: runSnapshot,
+}
+
+func runSnapshot(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+
+	archive, err := createBackup(ws, snapshotOptions.Output, "alf-snapshot", false)
+	if err != nil {
+		return fmt.Errorf("snapshot failed: %w", err)
+	}
+	fmt.Printf("Snapshot written to %s\n", archive)
+	return nil
+}
+
+func init() {
+	addWorkspaceFlag(snapshotCmd)
+	snapshotCmd.Flags().StringVarP(&snapshotOptions.Output, "output", "o", "backups", "Folder where the snapshot archive is written")
+
+	rootCmd.AddCommand(snapshotCmd)
+}
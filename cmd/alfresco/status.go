@@ -0,0 +1,176 @@
+package alfresco
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/aborroy/alf-cli/ui/selector"
+	"github.com/spf13/cobra"
+)
+
+var statusOptions struct {
+	Watch    bool
+	Interval time.Duration
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state, health and resource usage of every service",
+	Long: `Show, for every service of the generated compose.yaml, the container state, health,
+CPU and memory usage against the limits computed at generation time and the restart
+count, followed by the proxy URLs. With --watch the view refreshes until 'q' is pressed.`,
+	Args: cobra.NoArgs,
+	RunE: runStatus,
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+	links := proxyLinks(ws)
+
+	if statusOptions.Watch {
+		title := fmt.Sprintf("Alfresco %s • %s", ws.Version(), ws.ProjectName())
+		return selector.RunStatusDashboard(title, links, statusOptions.Interval, func() ([]selector.ServiceStatus, error) {
+			return serviceStatuses(ws)
+		})
+	}
+
+	rows, err := serviceStatuses(ws)
+	if err != nil {
+		return err
+	}
+	fmt.Print(selector.RenderStatusTable(rows, links))
+	return nil
+}
+
+// proxyLinks returns the public URLs of the applications published by the proxy
+func proxyLinks(ws *Workspace) []string {
+	base := ws.PublicURL()
+	links := []string{base + "/alfresco/", base + "/share/", base + "/content-app/", base + "/admin/"}
+	for _, service := range []string{"grafana", "prometheus"} {
+		if ws.Compose.HasService(service) {
+			links = append(links, base+"/"+service+"/")
+		}
+	}
+	return links
+}
+
+// containerInfo is the subset of "docker inspect" used by the status view
+type containerInfo struct {
+	ID           string `json:"Id"`
+	RestartCount int    `json:"RestartCount"`
+	State        struct {
+		Status string `json:"Status"`
+		Health *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+// serviceStatuses joins the services of compose.yaml with their containers and live usage
+func serviceStatuses(ws *Workspace) ([]selector.ServiceStatus, error) {
+	var ids bytes.Buffer
+	ps := ws.compose("ps", "--all", "-q")
+	ps.Stdout, ps.Stderr = &ids, nil
+	if err := ps.Run(); err != nil {
+		return nil, fmt.Errorf("docker compose ps: %w", err)
+	}
+
+	containers := make(map[string]containerInfo)
+	usage := make(map[string][2]float64)
+	if idList := strings.Fields(ids.String()); len(idList) > 0 {
+		out, err := exec.Command("docker", append([]string{"inspect"}, idList...)...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("docker inspect: %w", err)
+		}
+		var infos []containerInfo
+		if err := json.Unmarshal(out, &infos); err != nil {
+			return nil, fmt.Errorf("docker inspect: %w", err)
+		}
+		for _, info := range infos {
+			containers[info.Config.Labels["com.docker.compose.service"]] = info
+		}
+		usage = containerUsage(idList)
+	}
+
+	var rows []selector.ServiceStatus
+	for _, service := range ws.Compose.Services() {
+		row := selector.ServiceStatus{Service: service}
+		limits := ws.Compose.Service(service).Get("deploy", "resources", "limits")
+		if limits != nil {
+			row.CPULimit, _ = strconv.ParseFloat(limits.String("cpus"), 64)
+			if mib, err := util.FromHuman(limits.String("memory")); err == nil {
+				row.MemLimitMiB = float64(mib)
+			}
+		}
+		if info, ok := containers[service]; ok {
+			row.State = info.State.Status
+			row.Restarts = info.RestartCount
+			if info.State.Health != nil {
+				row.Health = info.State.Health.Status
+			}
+			if u, ok := usage[info.ID[:12]]; ok {
+				row.CPU, row.MemMiB = u[0], u[1]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// containerUsage returns CPU (in cores) and memory (in MiB) used by each running
+// container, keyed by short container ID. Errors leave the usage empty.
+func containerUsage(ids []string) map[string][2]float64 {
+	usage := make(map[string][2]float64)
+	out, err := exec.Command("docker", append([]string{"stats", "--no-stream", "--format", "{{.ID}} {{.CPUPerc}} {{.MemUsage}}"}, ids...)...).Output()
+	if err != nil {
+		return usage
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		// e.g. "0123456789ab 12.50% 512MiB / 2GiB"
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		cpu, _ := strconv.ParseFloat(strings.TrimSuffix(fields[1], "%"), 64)
+		usage[fields[0][:min(12, len(fields[0]))]] = [2]float64{cpu / 100, parseMiB(fields[2])}
+	}
+	return usage
+}
+
+// parseMiB converts a size printed by "docker stats" (e.g. "512MiB", "1.5GiB", "800kB") to MiB
+func parseMiB(size string) float64 {
+	units := []struct {
+		suffix string
+		mib    float64
+	}{
+		{"GiB", 1024}, {"MiB", 1}, {"KiB", 1.0 / 1024},
+		{"GB", 1e9 / (1 << 20)}, {"MB", 1e6 / (1 << 20)}, {"kB", 1e3 / (1 << 20)}, {"B", 1.0 / (1 << 20)},
+	}
+	for _, u := range units {
+		if value, found := strings.CutSuffix(size, u.suffix); found {
+			n, _ := strconv.ParseFloat(value, 64)
+			return n * u.mib
+		}
+	}
+	return 0
+}
+
+func init() {
+	addWorkspaceFlag(statusCmd)
+	statusCmd.Flags().BoolVarP(&statusOptions.Watch, "watch", "w", false, "Refresh the status in a live dashboard")
+	statusCmd.Flags().DurationVar(&statusOptions.Interval, "interval", 2*time.Second, "Refresh interval of the live dashboard")
+
+	rootCmd.AddCommand(statusCmd)
+}
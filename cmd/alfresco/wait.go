@@ -0,0 +1,278 @@
+package alfresco
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+// Image used to reach Solr over mTLS from the Compose network
+const curlImage = "docker.io/curlimages/curl:8.15.0"
+
+const readyProbePath = "/alfresco/api/-default-/public/alfresco/versions/1/probes/-ready-"
+
+var waitOptions struct {
+	Timeout  time.Duration
+	Interval time.Duration
+}
+
+var (
+	probeOKStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#8bdc01"))
+	probePendingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#fba100"))
+	probeDetailStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+var waitCmd = &cobra.Command{
+	Use:   "wait",
+	Short: "Wait until every service of a generated workspace is ready",
+	Long: `Poll the readiness endpoints of a running stack until all of them answer or the
+timeout expires: the repository -ready- probe, the transform/config endpoint of every
+T-Engine, the Solr core summary and the proxy routes /share/, /content-app/ and /admin/.
+The command exits with a non-zero status on timeout, so it can gate CI jobs.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runWait,
+}
+
+// readinessProbe is a single endpoint polled by "alf wait"
+type readinessProbe struct {
+	Name   string
+	Target string
+	Check  func() error
+	Ready  bool
+	Detail string
+}
+
+func runWait(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(workspaceDir)
+	if err != nil {
+		return err
+	}
+
+	probes := readinessProbes(ws)
+	live := isTerminal(os.Stdout)
+	deadline := time.Now().Add(waitOptions.Timeout)
+	started := time.Now()
+
+	for first := true; ; first = false {
+		var wg sync.WaitGroup
+		for _, p := range probes {
+			if p.Ready {
+				continue
+			}
+			wg.Add(1)
+			go func(p *readinessProbe) {
+				defer wg.Done()
+				wasDetail := p.Detail
+				if err := p.Check(); err != nil {
+					p.Detail = err.Error()
+				} else {
+					p.Ready, p.Detail = true, "ready"
+				}
+				if !live && p.Detail != wasDetail {
+					fmt.Printf("[%s] %-22s %s\n", time.Since(started).Round(time.Second), p.Name, p.Detail)
+				}
+			}(p)
+		}
+		wg.Wait()
+
+		if live {
+			printProbeTable(probes, time.Since(started), !first)
+		}
+
+		pending := 0
+		for _, p := range probes {
+			if !p.Ready {
+				pending++
+			}
+		}
+		if pending == 0 {
+			fmt.Printf("All services ready after %s\n", time.Since(started).Round(time.Second))
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d of %d probes not ready after %s", pending, len(probes), waitOptions.Timeout)
+		}
+		time.Sleep(waitOptions.Interval)
+	}
+}
+
+// readinessProbes lists the probes matching the services of the workspace
+func readinessProbes(ws *Workspace) []*readinessProbe {
+	probes := []*readinessProbe{{
+		Name:   "Repository",
+		Target: "alfresco",
+		Check:  func() error { return execProbe(ws, "alfresco", "http://localhost:8080"+readyProbePath, nil) },
+	}}
+
+	for _, service := range ws.Compose.Services() {
+		if !strings.HasPrefix(service, "transform-") {
+			continue
+		}
+		probes = append(probes, &readinessProbe{
+			Name:   "Transform",
+			Target: service,
+			Check:  func() error { return execProbe(ws, service, "http://localhost:8090/transform/config", nil) },
+		})
+	}
+
+	for _, service := range ws.SolrServices() {
+		probes = append(probes, &readinessProbe{
+			Name:   "Search",
+			Target: service,
+			Check:  func() error { return solrProbe(ws, service) },
+		})
+	}
+
+	for _, route := range []string{"/share/", "/content-app/", "/admin/"} {
+		url := ws.PublicURL() + route
+		probes = append(probes, &readinessProbe{
+			Name:   "Proxy " + route,
+			Target: url,
+			Check:  func() error { return httpProbe(ws, url) },
+		})
+	}
+	return probes
+}
+
+// execProbe runs curl inside a service container and returns an error unless it gets a 2xx answer.
+// The answer is written to out, or discarded when out is nil.
+func execProbe(ws *Workspace, service, url string, out io.Writer, headers ...string) error {
+	if out == nil {
+		out = io.Discard
+	}
+	args := []string{"exec", "-T", service, "curl", "-fsS", "--max-time", "10"}
+	for _, h := range headers {
+		args = append(args, "-H", h)
+	}
+	cmd := ws.compose(append(args, url)...)
+	var stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = out, &stderr
+	if err := cmd.Run(); err != nil {
+		return probeError(err, stderr.String())
+	}
+	return nil
+}
+
+// solrProbe requests the core summary of a Solr shard and checks that at least one core is loaded
+func solrProbe(ws *Workspace, service string) error {
+	const summary = "/solr/admin/cores?action=SUMMARY&wt=json"
+	var out bytes.Buffer
+
+	switch ws.SolrComm() {
+	case "https":
+		// Solr requires a client certificate, so the browser certificate is presented
+		// from a one-off container attached to the network of the service
+		password := ws.Env["SSL_BROWSER_PASSWORD"]
+		if password == "" {
+			password = "keystore"
+		}
+		cmd := exec.Command("docker", "run", "--rm",
+			"--network", ws.ServiceNetwork(service),
+			"-v", filepath.Join(ws.Dir, "keystores", "client")+":/certs:ro",
+			curlImage, "-fsS", "-k", "--max-time", "10",
+			"--cert-type", "P12", "--cert", "/certs/browser.p12:"+password,
+			"https://"+service+":8983"+summary)
+		var stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &out, &stderr
+		if err := cmd.Run(); err != nil {
+			return probeError(err, stderr.String())
+		}
+	case "secret":
+		if err := execProbe(ws, service, "http://localhost:8983"+summary, &out, "X-Alfresco-Search-Secret: "+ws.Secret("SECURE_COMMS_SECRET")); err != nil {
+			return err
+		}
+	default:
+		if err := execProbe(ws, service, "http://localhost:8983"+summary, &out); err != nil {
+			return err
+		}
+	}
+
+	var response struct {
+		Summary map[string]json.RawMessage `json:"Summary"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &response); err != nil {
+		return fmt.Errorf("unexpected core summary: %w", err)
+	}
+	if len(response.Summary) == 0 {
+		return errors.New("no core loaded yet")
+	}
+	return nil
+}
+
+// httpProbe requests a proxy route from the host; self-signed certificates are accepted
+func httpProbe(ws *Workspace, url string) error {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = ws.Env["SERVER_NAME"]
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.New("proxy not reachable")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// probeError keeps the last line printed by a failed probe command
+func probeError(err error, stderr string) error {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return errors.New(last)
+	}
+	return err
+}
+
+// printProbeTable draws the probe states, moving the cursor up to redraw in place
+func printProbeTable(probes []*readinessProbe, elapsed time.Duration, redraw bool) {
+	if redraw {
+		fmt.Printf("\x1b[%dA", len(probes)+1)
+	}
+	fmt.Printf("\x1b[2KWaiting for services (%s)\n", elapsed.Round(time.Second))
+	for _, p := range probes {
+		status := probePendingStyle.Render("…")
+		if p.Ready {
+			status = probeOKStyle.Render("✔")
+		}
+		detail := p.Detail
+		if len(detail) > 60 {
+			detail = detail[:57] + "..."
+		}
+		fmt.Printf("\x1b[2K  %s %-16s %-36s %s\n", status, p.Name, p.Target, probeDetailStyle.Render(detail))
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	addWorkspaceFlag(waitCmd)
+	waitCmd.Flags().DurationVar(&waitOptions.Timeout, "timeout", 10*time.Minute, "Maximum time to wait for the services")
+	waitCmd.Flags().DurationVar(&waitOptions.Interval, "interval", 5*time.Second, "Time between two polling rounds")
+
+	rootCmd.AddCommand(waitCmd)
+}
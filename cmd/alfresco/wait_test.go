@@ -0,0 +1,37 @@
+package alfresco
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fakeCompose replaces Docker Compose with a shell script for the duration of a test
+func fakeCompose(t *testing.T, script string) {
+	t.Helper()
+	saved := composeCmdLine
+	t.Cleanup(func() { composeCmdLine = saved })
+	// The compose arguments become the positional parameters of the script
+	composeCmdLine = []string{"sh", "-c", script, "compose"}
+}
+
+func TestExecProbe(t *testing.T) {
+	ws := &Workspace{Dir: t.TempDir()}
+
+	fakeCompose(t, `echo '{"entry":{"status":"UP"}}'`)
+	if err := execProbe(ws, "alfresco", "http://localhost:8080"+readyProbePath, nil); err != nil {
+		t.Fatalf("probe with discarded answer: %v", err)
+	}
+	var out bytes.Buffer
+	if err := execProbe(ws, "alfresco", "http://localhost:8080"+readyProbePath, &out); err != nil {
+		t.Fatalf("probe with captured answer: %v", err)
+	}
+	if got := out.String(); got != "{\"entry\":{\"status\":\"UP\"}}\n" {
+		t.Errorf("answer = %q", got)
+	}
+
+	fakeCompose(t, `echo "curl: (22) The requested URL returned error: 503" >&2; exit 22`)
+	err := execProbe(ws, "alfresco", "http://localhost:8080"+readyProbePath, nil)
+	if err == nil || err.Error() != "curl: (22) The requested URL returned error: 503" {
+		t.Errorf("failing probe: got %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+package alfresco
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aborroy/alf-cli/internal/util"
+	"github.com/spf13/cobra"
+)
+
+// Image used for one-off containers working on volumes and bind mounts
+const helperImage = "docker.io/library/alpine:3.20"
+
+// Folder of the workspace the current command works on
+var workspaceDir string
+
+// Workspace is a folder generated by the docker-compose command
+type Workspace struct {
+	Dir     string
+	Env     map[string]string
+	Compose *util.ComposeFile
+}
+
+// addWorkspaceFlag registers the --dir flag on a command working on a generated workspace
+func addWorkspaceFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&workspaceDir, "dir", "d", ".", "Folder containing the generated compose.yaml and .env")
+}
+
+// openWorkspace reads the .env and compose.yaml files of a generated workspace
+func openWorkspace(dir string) (*Workspace, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := util.ReadEnvFile(filepath.Join(abs, ".env"))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an alf-cli workspace: %w", abs, err)
+	}
+	compose, err := util.ReadComposeFile(filepath.Join(abs, "compose.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an alf-cli workspace: %w", abs, err)
+	}
+
+	return &Workspace{Dir: abs, Env: env, Compose: compose}, nil
+}
+
+// Version returns the ACS version (e.g. "25.2") from the repository image tag
+func (w *Workspace) Version() string {
+	parts := strings.Split(w.Env["REPO_TAG"], ".")
+	if len(parts) < 2 {
+		return w.Env["REPO_TAG"]
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// Database returns the database engine service ("postgres" or "mariadb")
+func (w *Workspace) Database() string {
+	if w.Compose.HasService("mariadb") {
+		return "mariadb"
+	}
+	return "postgres"
+}
+
+// ContentStore returns "s3" when the content is stored in the bundled MinIO, or "filesystem"
+func (w *Workspace) ContentStore() string {
+	if w.Compose.HasService("minio") {
+		return "s3"
+	}
+	return "filesystem"
+}
+
+// DbUser returns the database user, "alfresco" for workspaces without DB_USER in .env
+func (w *Workspace) DbUser() string {
+	if user := w.Env["DB_USER"]; user != "" {
+		return user
+	}
+	return "alfresco"
+}
+
+// DbName returns the database name, "alfresco" for workspaces without DB_NAME in .env
+func (w *Workspace) DbName() string {
+	if name := w.Env["DB_NAME"]; name != "" {
+		return name
+	}
+	return "alfresco"
+}
+
+// UseDockerVolume reports whether data is stored in Docker named volumes instead of ./data bind mounts
+func (w *Workspace) UseDockerVolume() bool {
+	return slices.Contains(w.Compose.Volumes(), "alf-repo-data")
+}
+
+// ProjectName returns the Compose project name, computed the way Compose does
+func (w *Workspace) ProjectName() string {
+	if name := w.Env["COMPOSE_PROJECT_NAME"]; name != "" {
+		return name
+	}
+	return defaultProjectName(w.Dir)
+}
+
+// ServiceNetwork returns the Docker network of a service: its first Compose network, or
+// the default one of workspaces generated before services were given networks
+func (w *Workspace) ServiceNetwork(service string) string {
+	network := "default"
+	if networks := w.Compose.Root.Strings("services", service, "networks"); len(networks) > 0 {
+		network = networks[0]
+	}
+	return w.ProjectName() + "_" + network
+}
+
+// defaultProjectName returns the project name Compose derives from a folder name
+func defaultProjectName(dir string) string {
+	name := strings.ToLower(filepath.Base(dir))
+	return regexp.MustCompile(`[^a-z0-9_-]`).ReplaceAllString(name, "")
+}
+
+// DataVolumes returns the data volumes mounted by the services: the named volumes, or
+// the folders bind mounted from ./data.
+func (w *Workspace) DataVolumes() []string {
+	if w.UseDockerVolume() {
+		return w.Compose.Volumes()
+	}
+	var volumes []string
+	for _, service := range w.Compose.Services() {
+		for _, mount := range w.Compose.Root.Strings("services", service, "volumes") {
+			source, _, _ := strings.Cut(mount, ":")
+			if strings.HasPrefix(source, "./data/") && !slices.Contains(volumes, filepath.Base(source)) {
+				volumes = append(volumes, filepath.Base(source))
+			}
+		}
+	}
+	return volumes
+}
+
+// SolrServices returns the Solr service of every shard
+func (w *Workspace) SolrServices() []string {
+	var services []string
+	for _, service := range w.Compose.Services() {
+		if strings.HasPrefix(service, "solr6") {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+// SolrComm returns the communication mode between the repository and Solr ("secret", "https" or "none")
+func (w *Workspace) SolrComm() string {
+	for _, opt := range strings.Fields(w.Compose.Root.String("services", "alfresco", "environment", "JAVA_OPTS")) {
+		if value, found := strings.CutPrefix(opt, "-Dsolr.secureComms="); found {
+			return value
+		}
+	}
+	return "none"
+}
+
+// HTTPS reports whether the proxy serves HTTPS
+func (w *Workspace) HTTPS() bool {
+	return strings.Contains(w.Compose.Root.String("services", "alfresco", "environment", "JAVA_OPTS"), "-Dalfresco.protocol=https")
+}
+
+// PublicURL returns the base URL of the proxy, reachable from the host
+func (w *Workspace) PublicURL() string {
+	scheme := "http"
+	if w.HTTPS() {
+		scheme = "https"
+	}
+	host := w.Env["BIND_IP_NGINX"]
+	if host == "" || host == "0.0.0.0" {
+		host = "localhost"
+	}
+	port := "8080"
+	if ports := w.Compose.Root.Strings("services", "proxy", "ports"); len(ports) > 0 {
+		port = ports[0][strings.LastIndex(ports[0], ":")+1:]
+	}
+	return fmt.Sprintf("%s://%s:%s", scheme, host, port)
+}
+
+// SolrVolumes returns the index volumes of every Solr shard
+func (w *Workspace) SolrVolumes() []string {
+	var volumes []string
+	for _, service := range w.Compose.Services() {
+		for _, mount := range w.Compose.Root.Strings("services", service, "volumes") {
+			source, target, _ := strings.Cut(mount, ":")
+			if strings.HasPrefix(target, "/opt/alfresco-search-services/data") {
+				volumes = append(volumes, filepath.Base(source))
+			}
+		}
+	}
+	return volumes
+}
+
+// VolumeSource returns what to mount in a one-off container to reach a data volume:
+// the Docker volume name, or the absolute path of the ./data bind mount.
+func (w *Workspace) VolumeSource(name string) string {
+	if w.UseDockerVolume() {
+		if explicit := w.Compose.Root.String("volumes", name, "name"); explicit != "" {
+			return explicit
+		}
+		return w.ProjectName() + "_" + name
+	}
+	return filepath.Join(w.Dir, "data", name)
+}
+
+// Command line of the detected Docker Compose, see composeCommand
+var composeCmdLine []string
+
+// composeCommand detects Docker Compose v2 ("docker compose") or falls back to the
+// standalone v1 binary ("docker-compose").
+func composeCommand() []string {
+	if composeCmdLine != nil {
+		return composeCmdLine
+	}
+	composeCmdLine = []string{"docker", "compose"}
+	if exec.Command("docker", "compose", "version").Run() != nil {
+		if _, err := exec.LookPath("docker-compose"); err == nil {
+			composeCmdLine = []string{"docker-compose"}
+		}
+	}
+	return composeCmdLine
+}
+
+// compose returns a Docker Compose command running in the workspace folder
+func (w *Workspace) compose(args ...string) *exec.Cmd {
+	line := composeCommand()
+	cmd := exec.Command(line[0], append(slices.Clone(line[1:]), args...)...)
+	cmd.Dir = w.Dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// runHelper runs a shell script in a one-off container with the given volumes mounted
+func (w *Workspace) runHelper(mounts []string, script string) error {
+	args := []string{"run", "--rm"}
+	for _, m := range mounts {
+		args = append(args, "-v", m)
+	}
+	args = append(args, helperImage, "sh", "-c", script)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Ownership of the data volumes, matching the one applied by create_volumes.sh
+var volumeOwners = map[string]string{
+	"alf-repo-data":   "33000:33000",
+	"solr-data":       "33007:33007",
+	"postgres-data":   "999:999",
+	"mariadb-data":    "999",
+	"activemq-data":   "33031:33031",
+	"prometheus-data": "65534:65534",
+	"minio-data":      "0:0",
+}
+
+// volumeOwner returns the uid[:gid] owning a data volume, or "" when it keeps the default
+func volumeOwner(name string) string {
+	if strings.HasPrefix(name, "solr-data") {
+		return volumeOwners["solr-data"]
+	}
+	return volumeOwners[name]
+}
+
+// Data volumes that create_volumes.sh chowns recursively
+var recursiveOwners = []string{"alf-repo-data", "activemq-data"}
+
+// chownVolume returns the command applying the ownership of a data volume mounted at target,
+// as create_volumes.sh does, or "" when the volume keeps the default ownership
+func chownVolume(name, target string) string {
+	owner := volumeOwner(name)
+	switch {
+	case owner == "":
+		return ""
+	case slices.Contains(recursiveOwners, name):
+		return fmt.Sprintf("chown -R %s %s", owner, target)
+	default:
+		return fmt.Sprintf("chown %s %s", owner, target)
+	}
+}
+
+// Configuration returns the subset of the generation settings that can be read back
+// from the workspace files.
+func (w *Workspace) Configuration() *Configuration {
+	return &Configuration{
+		Version:         w.Version(),
+		Database:        w.Database(),
+		UseDockerVolume: w.UseDockerVolume(),
+		UseActiveMQ:     w.Compose.HasService("activemq"),
+		SolrShards:      len(w.SolrVolumes()),
+		SolrComm:        w.SolrComm(),
+		ContentStore:    w.ContentStore(),
+	}
+}
+
+// RunningServices returns the services with a running container
+func (w *Workspace) RunningServices() ([]string, error) {
+	var stdout bytes.Buffer
+	cmd := w.compose("ps", "--services", "--filter", "status=running")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("list running services: %w", err)
+	}
+	return strings.Fields(stdout.String()), nil
+}
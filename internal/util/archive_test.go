@@ -0,0 +1,79 @@
+package util
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "db", "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"manifest.json": "{}", "db/alfresco.sql": "SELECT 1;"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := ArchiveDir(src, archive); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(archive); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("archive mode = %v, want 0600", info.Mode().Perm())
+	}
+	dst := t.TempDir()
+	if err := ExtractArchive(archive, dst); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		data, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil || string(data) != content {
+			t.Errorf("%s: got %q, %v, want %q", name, data, err, content)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dst, "db", "empty")); err != nil || !info.IsDir() {
+		t.Errorf("empty folder not extracted: %v", err)
+	}
+}
+
+func TestExtractArchiveRejectsEscapingEntries(t *testing.T) {
+	for _, name := range []string{"../evil", "db/../../evil", ".."} {
+		archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+		f, err := os.Create(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz := gzip.NewWriter(f)
+		tw := tar.NewWriter(gz)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: 4}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte("evil")); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		parent := t.TempDir()
+		dst := filepath.Join(parent, "restore")
+		if err := ExtractArchive(archive, dst); err == nil {
+			t.Errorf("entry %q accepted", name)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "evil")); err == nil {
+			t.Errorf("entry %q written outside the destination", name)
+		}
+	}
+}
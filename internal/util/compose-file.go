@@ -0,0 +1,283 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeNode is a node of a parsed compose.yaml: a scalar Value, a mapping
+// (Keys keeps the file order) or a List of scalars.
+type ComposeNode struct {
+	Value string
+	Keys  []string
+	Map   map[string]*ComposeNode
+	List  []string
+}
+
+// Get follows a path of mapping keys and returns nil when any key is missing.
+func (n *ComposeNode) Get(path ...string) *ComposeNode {
+	cur := n
+	for _, key := range path {
+		if cur == nil || cur.Map == nil {
+			return nil
+		}
+		cur = cur.Map[key]
+	}
+	return cur
+}
+
+// String returns the scalar value of the node at path, or "" when missing.
+func (n *ComposeNode) String(path ...string) string {
+	if node := n.Get(path...); node != nil {
+		return node.Value
+	}
+	return ""
+}
+
+// Strings returns the list items, or the mapping keys, of the node at path.
+func (n *ComposeNode) Strings(path ...string) []string {
+	node := n.Get(path...)
+	switch {
+	case node == nil:
+		return nil
+	case node.List != nil:
+		return node.List
+	default:
+		return node.Keys
+	}
+}
+
+// ComposeFile is the subset of a generated compose.yaml used by the workspace commands.
+type ComposeFile struct {
+	Root *ComposeNode
+}
+
+// Services returns the service names in file order.
+func (c *ComposeFile) Services() []string { return c.Root.Strings("services") }
+
+// Service returns the node describing a service, or nil when it is not defined.
+func (c *ComposeFile) Service(name string) *ComposeNode { return c.Root.Get("services", name) }
+
+// HasService reports whether the service is defined.
+func (c *ComposeFile) HasService(name string) bool { return c.Service(name) != nil }
+
+// Volumes returns the top-level named volumes.
+func (c *ComposeFile) Volumes() []string { return c.Root.Strings("volumes") }
+
+// DependsOn returns the services a service depends on.
+func (c *ComposeFile) DependsOn(name string) []string {
+	return c.Root.Strings("services", name, "depends_on")
+}
+
+// ReadComposeFile reads and parses the compose.yaml produced by the templates.
+func ReadComposeFile(path string) (*ComposeFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseComposeFile(path, data)
+}
+
+// ParseComposeFile parses a compose.yaml, named path in errors. Aliases and merge
+// keys are resolved; sequences are kept as lists of their scalar items.
+func ParseComposeFile(path string, data []byte) (*ComposeFile, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return &ComposeFile{Root: &ComposeNode{Map: map[string]*ComposeNode{}}}, nil
+	}
+	root := composeNode(doc.Content[0])
+	if root.Map == nil {
+		return nil, fmt.Errorf("%s: not a YAML mapping", path)
+	}
+	return &ComposeFile{Root: root}, nil
+}
+
+// composeNode converts a YAML node to a ComposeNode
+func composeNode(n *yaml.Node) *ComposeNode {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	node := &ComposeNode{}
+	switch n.Kind {
+	case yaml.MappingNode:
+		node.Map = map[string]*ComposeNode{}
+		set := func(key string, value *ComposeNode, override bool) {
+			if _, exists := node.Map[key]; !exists {
+				node.Keys = append(node.Keys, key)
+			} else if !override {
+				return
+			}
+			node.Map[key] = value
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Tag != "!!merge" {
+				set(key.Value, composeNode(value), true)
+				continue
+			}
+			// Keys set explicitly win over the merged ones, wherever the merge key is
+			merged := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				merged = value.Content
+			}
+			for _, m := range merged {
+				src := composeNode(m)
+				for _, k := range src.Keys {
+					set(k, src.Map[k], false)
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		node.List = make([]string, 0, len(n.Content))
+		for _, item := range n.Content {
+			node.List = append(node.List, composeNode(item).Value)
+		}
+	default:
+		node.Value = n.Value
+	}
+	return node
+}
+
+// ReadEnvFile parses a KEY=VALUE .env file, ignoring comments and blank lines.
+func ReadEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := make(map[string]string)
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		text := strings.TrimSpace(scan.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(text, "=")
+		if !found {
+			continue
+		}
+		env[strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+	}
+	return env, scan.Err()
+}
+
+// UpdateEnvFile sets the given keys in a .env file, keeping comments and the order
+// of the other lines. Keys not present yet are appended.
+func UpdateEnvFile(path string, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[string]string, len(values))
+	for k, v := range values {
+		pending[k] = v
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for i, line := range lines {
+		key, _, found := strings.Cut(strings.TrimSpace(line), "=")
+		if value, ok := pending[strings.TrimSpace(key)]; found && ok && !strings.HasPrefix(key, "#") {
+			lines[i] = strings.TrimSpace(key) + "=" + value
+			delete(pending, strings.TrimSpace(key))
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(pending)) {
+		lines = append(lines, key+"="+pending[key])
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), info.Mode().Perm())
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// StartupOrder groups the services by dependency level: services in a group only
+// depend on services from earlier groups. Services in a dependency cycle are
+// returned together in a last group.
+func (c *ComposeFile) StartupOrder() [][]string {
+	started := make(map[string]bool)
+	pending := c.Services()
+	var order [][]string
+	for len(pending) > 0 {
+		var group, rest []string
+		for _, service := range pending {
+			ready := true
+			for _, dep := range c.DependsOn(service) {
+				if c.HasService(dep) && !started[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				group = append(group, service)
+			} else {
+				rest = append(rest, service)
+			}
+		}
+		if len(group) == 0 {
+			return append(order, rest)
+		}
+		for _, service := range group {
+			started[service] = true
+		}
+		order = append(order, group)
+		pending = rest
+	}
+	return order
+}
+
+// ServicesUsing returns the services whose definition references the ${variable}
+// interpolated from .env, in file order.
+func (c *ComposeFile) ServicesUsing(variable string) []string {
+	var uses func(n *ComposeNode) bool
+	uses = func(n *ComposeNode) bool {
+		refs := func(s string) bool {
+			return strings.Contains(s, "${"+variable+"}") || strings.Contains(s, "${"+variable+":")
+		}
+		if refs(n.Value) || slices.ContainsFunc(n.List, refs) {
+			return true
+		}
+		for _, child := range n.Map {
+			if uses(child) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var services []string
+	for _, service := range c.Services() {
+		if uses(c.Service(service)) {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+// ServicesWithSecret returns the services granted a Docker secret, in file order.
+func (c *ComposeFile) ServicesWithSecret(name string) []string {
+	var services []string
+	for _, service := range c.Services() {
+		if slices.Contains(c.Root.Strings("services", service, "secrets"), name) {
+			services = append(services, service)
+		}
+	}
+	return services
+}
@@ -0,0 +1,237 @@
+package util
+
+import (
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseComposeFile(t *testing.T) {
+	const compose = `x-defaults: &defaults
+  restart: unless-stopped
+  environment:
+    TZ: UTC
+
+services:
+  db:
+    <<: *defaults
+    image: "postgres:16"
+    command: ["postgres", "-c", "max_connections=300"]
+    healthcheck:
+      test: [CMD, pg_isready]
+  app:
+    <<: *defaults
+    restart: always
+    "image": 'app:1'
+    environment:
+      JAVA_OPTS: >-
+        -Da=1
+        -Db=2
+      SCRIPT: |
+        echo one
+        echo two
+      QUOTED: "a
+        b"
+    depends_on:
+      db:
+        condition: service_healthy
+  web:
+    image: nginx
+    depends_on: [app]
+    ports:
+      - 8080:80
+
+volumes:
+  data:
+`
+	c, err := ParseComposeFile("compose.yaml", []byte(compose))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"services in file order", c.Services(), []string{"db", "app", "web"}},
+		{"merged key", c.Root.String("services", "db", "restart"), "unless-stopped"},
+		{"explicit key wins over merge", c.Root.String("services", "app", "restart"), "always"},
+		{"merged mapping", c.Root.String("services", "db", "environment", "TZ"), "UTC"},
+		{"double-quoted scalar", c.Root.String("services", "db", "image"), "postgres:16"},
+		{"quoted key and single-quoted scalar", c.Root.String("services", "app", "image"), "app:1"},
+		{"flow sequence", c.Root.Strings("services", "db", "command"), []string{"postgres", "-c", "max_connections=300"}},
+		{"plain flow sequence", c.Root.Strings("services", "db", "healthcheck", "test"), []string{"CMD", "pg_isready"}},
+		{"folded scalar", c.Root.String("services", "app", "environment", "JAVA_OPTS"), "-Da=1 -Db=2"},
+		{"literal scalar", c.Root.String("services", "app", "environment", "SCRIPT"), "echo one\necho two\n"},
+		{"multi-line quoted scalar", c.Root.String("services", "app", "environment", "QUOTED"), "a b"},
+		{"depends_on mapping", c.DependsOn("app"), []string{"db"}},
+		{"depends_on list", c.DependsOn("web"), []string{"app"}},
+		{"block sequence", c.Root.Strings("services", "web", "ports"), []string{"8080:80"}},
+		{"null volume", c.Volumes(), []string{"data"}},
+		{"missing path", c.Root.String("services", "nope", "image"), ""},
+	}
+	for _, tt := range tests {
+		switch want := tt.want.(type) {
+		case string:
+			if tt.got != want {
+				t.Errorf("%s: got %q, want %q", tt.name, tt.got, want)
+			}
+		case []string:
+			if !slices.Equal(tt.got.([]string), want) {
+				t.Errorf("%s: got %q, want %q", tt.name, tt.got, want)
+			}
+		}
+	}
+}
+
+func TestParseComposeFileErrors(t *testing.T) {
+	for _, data := range []string{"services: [a\n", "- not\n- a mapping\n"} {
+		if _, err := ParseComposeFile("compose.yaml", []byte(data)); err == nil {
+			t.Errorf("ParseComposeFile(%q) succeeded", data)
+		}
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	const env = `# Generated by alf-cli
+SERVER_NAME=localhost
+
+  DB_USER = alfresco
+DB_PASSWORD="quoted=value"
+AMQ_PASSWORD='single'
+JAVA_OPTS=-Da=1 -Db=2
+NOT A VARIABLE
+`
+	if err := os.WriteFile(path, []byte(env), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"SERVER_NAME":  "localhost",
+		"DB_USER":      "alfresco",
+		"DB_PASSWORD":  "quoted=value",
+		"AMQ_PASSWORD": "single",
+		"JAVA_OPTS":    "-Da=1 -Db=2",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := ReadEnvFile(filepath.Join(t.TempDir(), ".env")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file: got %v", err)
+	}
+}
+
+func TestStartupOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		compose string
+		want    [][]string
+	}{
+		{"levels in file order", `services:
+  proxy:
+    image: nginx
+    depends_on:
+      - alfresco
+      - share
+  share:
+    image: share
+    depends_on:
+      - alfresco
+  alfresco:
+    image: alfresco
+    depends_on:
+      postgres:
+        condition: service_healthy
+  postgres:
+    image: postgres
+  activemq:
+    image: activemq
+`, [][]string{{"postgres", "activemq"}, {"alfresco"}, {"share"}, {"proxy"}}},
+		{"undefined dependency ignored", `services:
+  alfresco:
+    image: alfresco
+    depends_on:
+      - postgres
+`, [][]string{{"alfresco"}}},
+		{"cycle in a last group", `services:
+  postgres:
+    image: postgres
+  a:
+    image: a
+    depends_on:
+      - b
+      - postgres
+  b:
+    image: b
+    depends_on:
+      - a
+`, [][]string{{"postgres"}, {"a", "b"}}},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "compose.yaml")
+		if err := os.WriteFile(path, []byte(tt.compose), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := ReadComposeFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := c.StartupOrder()
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	const env = `# Credentials
+DB_USER=alfresco
+DB_PASSWORD=old
+# DB_PASSWORD=commented
+SERVER_NAME=localhost
+`
+	if err := os.WriteFile(path, []byte(env), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateEnvFile(path, map[string]string{"DB_PASSWORD": "new", "B_NEW": "b", "A_NEW": "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `# Credentials
+DB_USER=alfresco
+DB_PASSWORD=new
+# DB_PASSWORD=commented
+SERVER_NAME=localhost
+A_NEW=a
+B_NEW=b
+`
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode not kept: %v", err)
+	}
+
+	values, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["DB_PASSWORD"] != "new" || values["A_NEW"] != "a" || values["DB_USER"] != "alfresco" {
+		t.Errorf("read back %q", values)
+	}
+}
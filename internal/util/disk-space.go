@@ -0,0 +1,14 @@
+//go:build !windows
+
+package util
+
+import "syscall"
+
+// FreeDiskBytes returns the space available to the current user on the filesystem holding path.
+func FreeDiskBytes(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
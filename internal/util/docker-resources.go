@@ -104,6 +104,21 @@ func (d *DockerResourceDetector) GetRAMBytes() (int64, error) {
 	}
 }
 
+// CgroupVersion reports the cgroup hierarchy used for the limits on Linux: "v2"
+// (unified), "v1" (per-controller) or "" when none is mounted or on other OSes.
+func (d *DockerResourceDetector) CgroupVersion() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		return "v2"
+	}
+	if paths, err := d.readCgroupPaths(); err == nil && paths["memory"] != "" {
+		return "v1"
+	}
+	return ""
+}
+
 // dockerInfoInt runs "docker info --format <fmt>"" and parses the result as int64.
 func (d *DockerResourceDetector) dockerInfoInt(goTemplate string) (int64, error) {
 	if _, err := exec.LookPath("docker"); err != nil {
@@ -0,0 +1,380 @@
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"unicode/utf16"
+)
+
+// PKCS#12 keystores readable by Java (keytool, Tomcat, Jetty) and OpenSSL.
+// Private keys are protected with PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC) and
+// the store integrity with an HMAC-SHA256 MAC, the defaults of keytool since Java 17.
+
+var (
+	oidData                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidShroudedKeyBag      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidSecretBag           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 5}
+	oidX509Certificate     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidJavaTrustedKeyUsage = asn1.ObjectIdentifier{2, 16, 840, 1, 113894, 746875, 1, 1}
+	oidAnyExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37, 0}
+	oidPBES2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHmacWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA256              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDESede              = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+const (
+	pkcs12Iterations = 10000
+	pkcs12SaltLength = 16
+)
+
+var errKeystoreEntryInvalid = errors.New("keystore entry needs one of a key, a secret or a trusted certificate")
+
+// KeystoreEntry is an entry of a PKCS#12 keystore. Exactly one of Key (with
+// its certificate Chain), Secret or Trusted is set.
+type KeystoreEntry struct {
+	Alias    string
+	Key      any                 // Private key, with Chain[0] as its certificate
+	Chain    []*x509.Certificate // Certificate chain of Key, leaf first
+	Secret   []byte              // DESede secret key, as used by the metadata keystore
+	Trusted  *x509.Certificate   // Trusted certificate entry
+	Password string              // Key password, defaults to the store password
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int
+	Prf        algorithmIdentifier
+}
+
+type pbes2Params struct {
+	Kdf algorithmIdentifier
+	Enc algorithmIdentifier
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm algorithmIdentifier
+	Data      []byte
+}
+
+type pkcs8SecretKey struct {
+	Version   int
+	Algorithm algorithmIdentifier
+	Key       []byte
+}
+
+type certBag struct {
+	ID    asn1.ObjectIdentifier
+	Value []byte `asn1:"explicit,tag:0"`
+}
+
+// Context specific [0] EXPLICIT fields are RawValues wrapped with
+// wrapExplicit: encoding/asn1 ignores the explicit tag of a RawValue.
+
+type secretBag struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type pkcs12Attribute struct {
+	ID     asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type digestInfo struct {
+	Algorithm algorithmIdentifier
+	Digest    []byte
+}
+
+type macData struct {
+	Mac        digestInfo
+	Salt       []byte
+	Iterations int
+}
+
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+// EncodePKCS12 returns a PKCS#12 keystore holding the entries, protected with storePassword.
+func EncodePKCS12(entries []KeystoreEntry, storePassword string) ([]byte, error) {
+	var bags []safeBag
+	for i, entry := range entries {
+		password := entry.Password
+		if password == "" {
+			password = storePassword
+		}
+		localKeyID := []byte(fmt.Sprintf("Time %d", i+1))
+		kinds := 0
+		for _, set := range []bool{entry.Key != nil, entry.Secret != nil, entry.Trusted != nil} {
+			if set {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			return nil, fmt.Errorf("%s: %w", entry.Alias, errKeystoreEntryInvalid)
+		}
+
+		switch {
+		case entry.Key != nil:
+			if len(entry.Chain) == 0 {
+				return nil, fmt.Errorf("keystore entry %s: missing certificate", entry.Alias)
+			}
+			der, err := x509.MarshalPKCS8PrivateKey(entry.Key)
+			if err != nil {
+				return nil, err
+			}
+			encrypted, err := encryptPBES2(der, password)
+			if err != nil {
+				return nil, err
+			}
+			bags = append(bags, newSafeBag(oidShroudedKeyBag, encrypted, friendlyName(entry.Alias), localKeyIDAttribute(localKeyID)))
+			for j, cert := range entry.Chain {
+				var attrs []pkcs12Attribute
+				if j == 0 {
+					attrs = append(attrs, friendlyName(entry.Alias), localKeyIDAttribute(localKeyID))
+				}
+				bag, err := newCertBag(cert, attrs...)
+				if err != nil {
+					return nil, err
+				}
+				bags = append(bags, bag)
+			}
+
+		case entry.Secret != nil:
+			der, err := asn1.Marshal(pkcs8SecretKey{Algorithm: algorithmIdentifier{Algorithm: oidDESede}, Key: entry.Secret})
+			if err != nil {
+				return nil, err
+			}
+			encrypted, err := encryptPBES2(der, password)
+			if err != nil {
+				return nil, err
+			}
+			value, err := asn1.Marshal(secretBag{ID: oidShroudedKeyBag, Value: asn1.RawValue{FullBytes: wrapExplicit(encrypted)}})
+			if err != nil {
+				return nil, err
+			}
+			bags = append(bags, newSafeBag(oidSecretBag, value, friendlyName(entry.Alias), localKeyIDAttribute(localKeyID)))
+
+		case entry.Trusted != nil:
+			usage, err := asn1.Marshal(oidAnyExtendedKeyUsage)
+			if err != nil {
+				return nil, err
+			}
+			bag, err := newCertBag(entry.Trusted, friendlyName(entry.Alias), attribute(oidJavaTrustedKeyUsage, usage))
+			if err != nil {
+				return nil, err
+			}
+			bags = append(bags, bag)
+		}
+	}
+
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return nil, err
+	}
+	authSafe, err := asn1.Marshal([]contentInfo{dataContentInfo(safeContents)})
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, pkcs12SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	macKey := pkcs12KDF(bmpString(storePassword), salt, pkcs12Iterations, 3, sha256.Size)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(authSafe)
+
+	return asn1.Marshal(pfx{
+		Version:  3,
+		AuthSafe: dataContentInfo(authSafe),
+		MacData: macData{
+			Mac:        digestInfo{Algorithm: algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}, Digest: mac.Sum(nil)},
+			Salt:       salt,
+			Iterations: pkcs12Iterations,
+		},
+	})
+}
+
+func dataContentInfo(data []byte) contentInfo {
+	octets, _ := asn1.Marshal(data)
+	return contentInfo{ContentType: oidData, Content: asn1.RawValue{FullBytes: wrapExplicit(octets)}}
+}
+
+// wrapExplicit wraps DER bytes in a [0] EXPLICIT tag
+func wrapExplicit(der []byte) []byte {
+	wrapped, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der})
+	return wrapped
+}
+
+func newSafeBag(id asn1.ObjectIdentifier, value []byte, attrs ...pkcs12Attribute) safeBag {
+	return safeBag{ID: id, Value: asn1.RawValue{FullBytes: wrapExplicit(value)}, Attributes: attrs}
+}
+
+func newCertBag(cert *x509.Certificate, attrs ...pkcs12Attribute) (safeBag, error) {
+	value, err := asn1.Marshal(certBag{ID: oidX509Certificate, Value: cert.Raw})
+	if err != nil {
+		return safeBag{}, err
+	}
+	return newSafeBag(oidCertBag, value, attrs...), nil
+}
+
+func attribute(id asn1.ObjectIdentifier, value []byte) pkcs12Attribute {
+	return pkcs12Attribute{ID: id, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: value}}
+}
+
+func friendlyName(alias string) pkcs12Attribute {
+	name := bmpString(alias)
+	value, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: 30, Bytes: name[:len(name)-2]}) // BMPString, no NUL
+	return attribute(oidFriendlyName, value)
+}
+
+func localKeyIDAttribute(id []byte) pkcs12Attribute {
+	value, _ := asn1.Marshal(id)
+	return attribute(oidLocalKeyID, value)
+}
+
+// encryptPBES2 encrypts data with PBKDF2-HMAC-SHA256 and AES-256-CBC and
+// returns the DER encoded EncryptedPrivateKeyInfo.
+func encryptPBES2(data []byte, password string) ([]byte, error) {
+	salt := make([]byte, pkcs12SaltLength)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, pkcs12Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	padded := append(append([]byte{}, data...), make([]byte, padding)...)
+	for i := len(data); i < len(padded); i++ {
+		padded[i] = byte(padding)
+	}
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: pkcs12Iterations,
+		KeyLength:  32,
+		Prf:        algorithmIdentifier{Algorithm: oidHmacWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		Kdf: algorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		Enc: algorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: algorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		Data:      encrypted,
+	})
+}
+
+// bmpString returns the password as a NUL terminated big-endian UTF-16 string (RFC 7292, B.1)
+func bmpString(s string) []byte {
+	var out []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(r>>8), byte(r))
+	}
+	return append(out, 0, 0)
+}
+
+// pkcs12KDF derives key material with SHA-256 as described in RFC 7292, appendix B.2
+func pkcs12KDF(password, salt []byte, iterations int, id byte, size int) []byte {
+	const v = 64 // SHA-256 block size
+
+	fill := func(in []byte) []byte {
+		if len(in) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(in)+v-1)/v))
+		for i := range out {
+			out[i] = in[i%len(in)]
+		}
+		return out
+	}
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+	in := append(fill(salt), fill(password)...)
+
+	var out []byte
+	one := big.NewInt(1)
+	for len(out) < size {
+		h := sha256.New()
+		h.Write(d)
+		h.Write(in)
+		a := h.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			sum := sha256.Sum256(a)
+			a = sum[:]
+		}
+		out = append(out, a...)
+
+		b := new(big.Int).SetBytes(fill(a)[:v])
+		b.Add(b, one)
+		for j := 0; j < len(in); j += v {
+			block := new(big.Int).SetBytes(in[j : j+v])
+			block.Add(block, b)
+			sum := block.Bytes()
+			if len(sum) > v {
+				sum = sum[len(sum)-v:]
+			}
+			copy(in[j:j+v], make([]byte, v))
+			copy(in[j+v-len(sum):j+v], sum)
+		}
+	}
+	return out[:size]
+}
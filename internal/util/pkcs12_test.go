@@ -0,0 +1,269 @@
+package util
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	gopkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// The keystores are checked against go-pkcs12, which has no secret key support, and, when
+// installed, openssl and keytool: the stores must be read by other implementations.
+
+func testChain(t *testing.T) (ca, leaf *CertKey) {
+	t.Helper()
+	ca, err := NewCA("Test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err = ca.Issue(CertRequest{CommonName: "alfresco", DNSNames: []string{"alfresco"}, Server: true, Client: true, Validity: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, leaf
+}
+
+func TestEncodePKCS12KeyEntry(t *testing.T) {
+	ca, leaf := testChain(t)
+	data, err := EncodePKCS12([]KeystoreEntry{
+		{Alias: "ssl.repo", Key: leaf.Key, Chain: []*x509.Certificate{leaf.Cert, ca.Cert}},
+		{Alias: "ssl.ca", Trusted: ca.Cert},
+	}, "store-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, cert, caCerts, err := gopkcs12.DecodeChain(data, "store-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !leaf.Key.Equal(key) {
+		t.Error("decoded private key differs")
+	}
+	if !cert.Equal(leaf.Cert) {
+		t.Errorf("decoded certificate is %s, want %s", cert.Subject, leaf.Cert.Subject)
+	}
+	// The CA of the chain followed by the trusted entry
+	if len(caCerts) != 2 || !caCerts[0].Equal(ca.Cert) || !caCerts[1].Equal(ca.Cert) {
+		t.Errorf("decoded %d CA certificates, want the CA twice", len(caCerts))
+	}
+
+	if _, _, _, err := gopkcs12.DecodeChain(data, "wrong-password"); err == nil {
+		t.Error("keystore decoded with a wrong password")
+	}
+}
+
+func TestEncodePKCS12TrustStore(t *testing.T) {
+	ca, leaf := testChain(t)
+	data, err := EncodePKCS12([]KeystoreEntry{
+		{Alias: "ssl.ca", Trusted: ca.Cert},
+		{Alias: "ssl.repo", Trusted: leaf.Cert},
+	}, "store-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// DecodeTrustStore only accepts certificates carrying the Java trusted key usage
+	certs, err := gopkcs12.DecodeTrustStore(data, "store-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 || !certs[0].Equal(ca.Cert) || !certs[1].Equal(leaf.Cert) {
+		t.Errorf("decoded %d certificates, want the CA and the leaf", len(certs))
+	}
+}
+
+// decodeSecrets returns the secret keys of a keystore by alias, decrypted with password
+func decodeSecrets(t *testing.T, data []byte, password string) map[string][]byte {
+	t.Helper()
+	unmarshal := func(der []byte, v any) {
+		t.Helper()
+		if rest, err := asn1.Unmarshal(der, v); err != nil || len(rest) > 0 {
+			t.Fatalf("unmarshal %T: %v (%d trailing bytes)", v, err, len(rest))
+		}
+	}
+	var store pfx
+	var content []byte
+	var authSafe []contentInfo
+	unmarshal(data, &store)
+	unmarshal(store.AuthSafe.Content.Bytes, &content)
+	unmarshal(content, &authSafe)
+
+	secrets := make(map[string][]byte)
+	for _, info := range authSafe {
+		var bags []safeBag
+		unmarshal(info.Content.Bytes, &content)
+		unmarshal(content, &bags)
+		for _, bag := range bags {
+			if !bag.ID.Equal(oidSecretBag) {
+				continue
+			}
+			var alias string
+			for _, attr := range bag.Attributes {
+				if attr.ID.Equal(oidFriendlyName) {
+					var name asn1.RawValue
+					unmarshal(attr.Values.Bytes, &name)
+					units := make([]uint16, len(name.Bytes)/2)
+					for i := range units {
+						units[i] = uint16(name.Bytes[2*i])<<8 | uint16(name.Bytes[2*i+1])
+					}
+					alias = string(utf16.Decode(units))
+				}
+			}
+
+			var secret secretBag
+			var key encryptedPrivateKeyInfo
+			var params pbes2Params
+			var kdf pbkdf2Params
+			var iv []byte
+			unmarshal(bag.Value.Bytes, &secret)
+			if !secret.ID.Equal(oidShroudedKeyBag) {
+				t.Fatalf("%s: secret bag of type %v", alias, secret.ID)
+			}
+			unmarshal(secret.Value.Bytes, &key)
+			unmarshal(key.Algorithm.Parameters.FullBytes, &params)
+			unmarshal(params.Kdf.Parameters.FullBytes, &kdf)
+			unmarshal(params.Enc.Parameters.FullBytes, &iv)
+
+			derived, err := pbkdf2.Key(sha256.New, password, kdf.Salt, kdf.Iterations, kdf.KeyLength)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block, err := aes.NewCipher(derived)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain := make([]byte, len(key.Data))
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, key.Data)
+			padding := int(plain[len(plain)-1])
+			if padding == 0 || padding > aes.BlockSize {
+				t.Fatalf("%s: invalid padding, wrong password?", alias)
+			}
+
+			var secretKey pkcs8SecretKey
+			unmarshal(plain[:len(plain)-padding], &secretKey)
+			if !secretKey.Algorithm.Algorithm.Equal(oidDESede) {
+				t.Errorf("%s: secret key algorithm %v, want DESede", alias, secretKey.Algorithm.Algorithm)
+			}
+			secrets[alias] = secretKey.Key
+		}
+	}
+	return secrets
+}
+
+func TestEncodePKCS12SecretEntry(t *testing.T) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	data, err := EncodePKCS12([]KeystoreEntry{{Alias: "metadata", Secret: secret, Password: "key-password"}}, "store-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := decodeSecrets(t, data, "key-password")
+	if len(secrets) != 1 || !bytes.Equal(secrets["metadata"], secret) {
+		t.Errorf("decoded secrets %x, want metadata: %x", secrets, secret)
+	}
+}
+
+func TestEncodePKCS12Invalid(t *testing.T) {
+	_, leaf := testChain(t)
+	for name, entry := range map[string]KeystoreEntry{
+		"empty":          {Alias: "empty"},
+		"key and secret": {Alias: "both", Key: leaf.Key, Chain: []*x509.Certificate{leaf.Cert}, Secret: make([]byte, 24)},
+		"key, no chain":  {Alias: "nochain", Key: leaf.Key},
+	} {
+		if _, err := EncodePKCS12([]KeystoreEntry{entry}, "store-password"); err == nil {
+			t.Errorf("%s: entry accepted", name)
+		}
+	}
+}
+
+func TestEncodePKCS12OpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not installed")
+	}
+	ca, leaf := testChain(t)
+	secret := bytes.Repeat([]byte{0x5b}, 24)
+	data, err := EncodePKCS12([]KeystoreEntry{
+		{Alias: "ssl.repo", Key: leaf.Key, Chain: []*x509.Certificate{leaf.Cert, ca.Cert}},
+		{Alias: "ssl.ca", Trusted: ca.Cert},
+		{Alias: "metadata", Secret: secret},
+	}, "store-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keystore.p12")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(openssl, "pkcs12", "-in", path, "-info", "-nodes", "-passin", "pass:store-password").CombinedOutput()
+	if err != nil {
+		t.Fatalf("openssl pkcs12: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		"MAC: sha256",
+		"PBES2, PBKDF2, AES-256-CBC",
+		"friendlyName: ssl.repo",
+		"friendlyName: ssl.ca",
+		"friendlyName: metadata",
+		"Secret bag",
+		"Bag Type: pkcs8ShroudedKeyBag",
+		"subject=O = Alfresco Software, OU = alf-cli, CN = alfresco",
+		"BEGIN PRIVATE KEY",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("openssl output misses %q:\n%s", want, out)
+		}
+	}
+
+	if out, err := exec.Command(openssl, "pkcs12", "-in", path, "-noout", "-passin", "pass:wrong-password").CombinedOutput(); err == nil {
+		t.Errorf("openssl accepted a wrong password:\n%s", out)
+	}
+}
+
+func TestEncodePKCS12Keytool(t *testing.T) {
+	keytool, err := exec.LookPath("keytool")
+	if err != nil {
+		t.Skip("keytool not installed")
+	}
+	ca, leaf := testChain(t)
+	data, err := EncodePKCS12([]KeystoreEntry{
+		{Alias: "ssl.repo", Key: leaf.Key, Chain: []*x509.Certificate{leaf.Cert, ca.Cert}},
+		{Alias: "ssl.ca", Trusted: ca.Cert},
+		{Alias: "metadata", Secret: bytes.Repeat([]byte{0x5b}, 24)},
+	}, "store-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keystore.p12")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(keytool, "-list", "-storetype", "PKCS12", "-keystore", path, "-storepass", "store-password").CombinedOutput()
+	if err != nil {
+		t.Fatalf("keytool -list: %v\n%s", err, out)
+	}
+	for _, want := range []string{"ssl.repo, ", "PrivateKeyEntry", "ssl.ca, ", "trustedCertEntry", "metadata, ", "SecretKeyEntry"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("keytool output misses %q:\n%s", want, out)
+		}
+	}
+}
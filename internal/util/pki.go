@@ -0,0 +1,189 @@
+package util
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+// RSA key size of the generated certificates
+const rsaKeySize = 2048
+
+// CertKey is a certificate together with its private key
+type CertKey struct {
+	Cert *x509.Certificate
+	Key  *rsa.PrivateKey
+}
+
+// CertRequest describes a certificate issued by a CertKey acting as CA
+type CertRequest struct {
+	CommonName  string
+	DNSNames    []string
+	IPAddresses []net.IP
+	Server      bool // serverAuth extended key usage
+	Client      bool // clientAuth extended key usage
+	Validity    time.Duration
+}
+
+// NewCA creates a self-signed certificate authority.
+func NewCA(commonName string, validity time.Duration) (*CertKey, error) {
+	key, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := certTemplate(commonName, validity, &key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.MaxPathLenZero = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CertKey{Cert: cert, Key: key}, nil
+}
+
+// Issue creates a key pair and a certificate signed by the CA.
+func (ca *CertKey) Issue(req CertRequest) (*CertKey, error) {
+	key, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := certTemplate(req.CommonName, req.Validity, &key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.DNSNames = req.DNSNames
+	tmpl.IPAddresses = req.IPAddresses
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	tmpl.BasicConstraintsValid = true
+	if req.Server {
+		tmpl.ExtKeyUsage = append(tmpl.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+	}
+	if req.Client {
+		tmpl.ExtKeyUsage = append(tmpl.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Cert, &key.PublicKey, ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate %s: %w", req.CommonName, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CertKey{Cert: cert, Key: key}, nil
+}
+
+// CertPEM returns the PEM encoded certificate.
+func (c *CertKey) CertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Cert.Raw})
+}
+
+// KeyPEM returns the PEM encoded PKCS#1 private key.
+func (c *CertKey) KeyPEM() []byte {
+	return EncodeKeyPEM(c.Key)
+}
+
+// NewKey generates an RSA key of the size used by the generated certificates.
+func NewKey() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, rsaKeySize)
+}
+
+// EncodeKeyPEM returns the PEM encoded PKCS#1 private key.
+func EncodeKeyPEM(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// ParseCertificatesPEM returns every certificate of a PEM file, in file order.
+func ParseCertificatesPEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// ParsePrivateKeyPEM returns the first private key of a PEM file (PKCS#1, PKCS#8 or SEC 1).
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM private key found")
+		}
+		var key any
+		var err error
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+}
+
+// MatchesKey reports whether the certificate holds the public key of the private key.
+func MatchesKey(cert *x509.Certificate, key crypto.Signer) bool {
+	pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(key.Public())
+}
+
+func certTemplate(commonName string, validity time.Duration, pub *rsa.PublicKey) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	skid := sha1.Sum(x509.MarshalPKCS1PublicKey(pub))
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Alfresco Software"}, OrganizationalUnit: []string{"alf-cli"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+		SubjectKeyId: skid[:],
+	}, nil
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// CheckPortFree returns an error when the TCP port cannot be bound on ip.
+func CheckPortFree(ip, port string) error {
+	l, err := net.Listen("tcp", net.JoinHostPort(ip, port))
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// IsPortFree reports whether the TCP port can be bound on ip.
+func IsPortFree(ip string, port int) bool {
+	return CheckPortFree(ip, strconv.Itoa(port)) == nil
+}
+
+// FreePortShift returns the smallest shift (>= 0) that makes every port of the
+// group free on ip, so related ports (e.g. FTP control and data ports) move together.
+func FreePortShift(ip string, ports []int) (int, error) {
+	highest := 0
+	for _, p := range ports {
+		highest = max(highest, p)
+	}
+	for shift := 0; highest+shift <= 65535; shift++ {
+		free := true
+		for _, p := range ports {
+			if !IsPortFree(ip, p+shift) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return shift, nil
+		}
+	}
+	return 0, fmt.Errorf("no free port range found for %v on %s", ports, ip)
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 )
 
@@ -19,20 +20,35 @@ type (
 	}
 )
 
-// Scale returns a new map with every limit / reservation
-// multiplied so that the **totals** equal targetMiB / targetCPU.
+// Scale returns a new map with the limit / reservation of every entry in
+// services multiplied so that the **totals** equal targetMiB / targetCPU.
 // Any service not listed in `defaults` is ignored.
-func Scale(targetMiB int64, targetCPU float64) (map[string]Resource, error) {
+// The "solr6" entry is the budget of a single shard: the search budget
+// is split evenly across solrShards instances.
+func Scale(targetMiB int64, targetCPU float64, services []string, solrShards int) (map[string]Resource, error) {
+	if solrShards < 1 {
+		return nil, fmt.Errorf("invalid number of Solr shards: %d", solrShards)
+	}
+	selected := make(map[string]Resource, len(services))
+	for _, name := range services {
+		if r, ok := defaults[name]; ok {
+			selected[name] = r
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no known services to scale")
+	}
+
 	limitMiB, limitCPU := 0, 0.0
-	for _, r := range defaults {
+	for _, r := range selected {
 		limitMiB += int(r.Limits.MiB)
 		limitCPU += r.Limits.CPU
 	}
 	memFactor := float64(targetMiB) / float64(limitMiB)
 	cpuFactor := targetCPU / limitCPU
 
-	out := make(map[string]Resource, len(defaults))
-	for name, r := range defaults {
+	out := make(map[string]Resource, len(selected))
+	for name, r := range selected {
 		out[name] = Resource{
 			Limits: CPUMem{
 				CPU: round(r.Limits.CPU * cpuFactor),
@@ -44,9 +60,20 @@ func Scale(targetMiB int64, targetCPU float64) (map[string]Resource, error) {
 			},
 		}
 	}
+
+	if search, ok := out["solr6"]; ok {
+		out["solr6"] = Resource{
+			Limits:       split(search.Limits, solrShards),
+			Reservations: split(search.Reservations, solrShards),
+		}
+	}
 	return out, nil
 }
 
+func split(r CPUMem, n int) CPUMem {
+	return CPUMem{CPU: max(round(r.CPU/float64(n)), .01), MiB: r.MiB / int64(n)}
+}
+
 func round(f float64) float64 { return math.Round(f*100) / 100 }
 
 func FormatMem(miB int64) string {
@@ -69,6 +96,46 @@ var defaults = map[string]Resource{
 		Limits:       CPUMem{CPU: 2, MiB: 2048},
 		Reservations: CPUMem{CPU: 1, MiB: 1024},
 	},
+	"transform-imagemagick": {
+		Limits:       CPUMem{CPU: 1, MiB: 1024},
+		Reservations: CPUMem{CPU: .5, MiB: 512},
+	},
+	"transform-libreoffice": {
+		Limits:       CPUMem{CPU: 2, MiB: 2048},
+		Reservations: CPUMem{CPU: 1, MiB: 1024},
+	},
+	"transform-pdfrenderer": {
+		Limits:       CPUMem{CPU: .5, MiB: 512},
+		Reservations: CPUMem{CPU: .25, MiB: 256},
+	},
+	"transform-tika": {
+		Limits:       CPUMem{CPU: 1, MiB: 1024},
+		Reservations: CPUMem{CPU: .5, MiB: 512},
+	},
+	"transform-misc": {
+		Limits:       CPUMem{CPU: .5, MiB: 512},
+		Reservations: CPUMem{CPU: .25, MiB: 256},
+	},
+	"transform-ocr": {
+		Limits:       CPUMem{CPU: 2, MiB: 2048},
+		Reservations: CPUMem{CPU: 1, MiB: 1024},
+	},
+	"minio": {
+		Limits:       CPUMem{CPU: .5, MiB: 512},
+		Reservations: CPUMem{CPU: .25, MiB: 256},
+	},
+	"prometheus": {
+		Limits:       CPUMem{CPU: .5, MiB: 1024},
+		Reservations: CPUMem{CPU: .25, MiB: 512},
+	},
+	"grafana": {
+		Limits:       CPUMem{CPU: .5, MiB: 512},
+		Reservations: CPUMem{CPU: .25, MiB: 256},
+	},
+	"database-exporter": {
+		Limits:       CPUMem{CPU: .25, MiB: 128},
+		Reservations: CPUMem{CPU: .1, MiB: 64},
+	},
 	"alfresco": {
 		Limits:       CPUMem{CPU: 2, MiB: 3072},
 		Reservations: CPUMem{CPU: 1, MiB: 2048},
@@ -98,19 +165,22 @@ var defaults = map[string]Resource{
 // FromHuman: 20g to 20480 MiB
 func FromHuman(s string) (int64, error) {
 	s = strings.TrimSpace(strings.ToLower(s))
-	scale := int64(1)
-	switch {
-	case strings.HasSuffix(s, "g"), strings.HasSuffix(s, "gb"):
-		scale = 1024
-		s = strings.TrimSuffix(strings.TrimSuffix(s, "g"), "b")
-	case strings.HasSuffix(s, "m"), strings.HasSuffix(s, "mb"):
-		s = strings.TrimSuffix(strings.TrimSuffix(s, "m"), "b")
-	default:
+	var scale int64
+	for _, unit := range []struct {
+		suffix string
+		scale  int64
+	}{{"gb", 1024}, {"g", 1024}, {"mb", 1}, {"m", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, scale = strings.TrimSuffix(s, unit.suffix), unit.scale
+			break
+		}
+	}
+	if scale == 0 {
 		return 0, fmt.Errorf("use m/mb or g/gb")
 	}
-	val, err := fmt.Sscanf(s, "%d", &scale)
-	if err != nil || val != 1 {
+	val, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || val < 0 {
 		return 0, fmt.Errorf("cannot parse %q", s)
 	}
-	return scale * scale, nil // val * MiB
+	return val * scale, nil // val * MiB
 }
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScaleSolrShards(t *testing.T) {
+	services := []string{"database", "alfresco", "solr6", "share", "proxy", "unknown"}
+	single, err := Scale(16*1024, 8, services, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := single["unknown"]; ok {
+		t.Error("unknown service scaled")
+	}
+	limitMiB, limitCPU := int64(0), 0.0
+	for _, r := range single {
+		limitMiB += r.Limits.MiB
+		limitCPU += r.Limits.CPU
+	}
+	// Memory is truncated and CPUs rounded per service
+	if limitMiB > 16*1024 || limitMiB < 16*1024-int64(len(single)) {
+		t.Errorf("memory limits add up to %d MiB, want 16384", limitMiB)
+	}
+	if math.Abs(limitCPU-8) > .01*float64(len(single)) {
+		t.Errorf("CPU limits add up to %.2f, want 8", limitCPU)
+	}
+
+	sharded, err := Scale(16*1024, 8, services, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, r := range single {
+		want := r
+		if name == "solr6" {
+			want = Resource{Limits: split(r.Limits, 3), Reservations: split(r.Reservations, 3)}
+		}
+		if sharded[name] != want {
+			t.Errorf("%s with 3 shards: got %+v, want %+v", name, sharded[name], want)
+		}
+	}
+	if solr := sharded["solr6"].Limits; solr.MiB != single["solr6"].Limits.MiB/3 {
+		t.Errorf("solr6 shard memory = %d MiB, want a third of %d", solr.MiB, single["solr6"].Limits.MiB)
+	}
+
+	// Every shard keeps a minimal CPU share
+	tiny, err := Scale(1024, .1, []string{"alfresco", "solr6"}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu := tiny["solr6"].Reservations.CPU; cpu != .01 {
+		t.Errorf("solr6 shard reservation = %.2f CPU, want .01", cpu)
+	}
+}
+
+func TestScaleErrors(t *testing.T) {
+	if _, err := Scale(1024, 1, []string{"solr6"}, 0); err == nil {
+		t.Error("0 Solr shards accepted")
+	}
+	if _, err := Scale(1024, 1, []string{"unknown"}, 1); err == nil {
+		t.Error("no known services accepted")
+	}
+}
+
+func TestFromHuman(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"512m", 512, true},
+		{"512mb", 512, true},
+		{"8g", 8192, true},
+		{"8gb", 8192, true},
+		{" 2G ", 2048, true},
+		{"4GB", 4096, true},
+		{"8", 0, false},
+		{"8kb", 0, false},
+		{"gb", 0, false},
+		{"8xgb", 0, false},
+		{"1.5g", 0, false},
+		{"-1g", 0, false},
+		{"8gbb", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := FromHuman(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("FromHuman(%q) = %d, %v; want %d, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// StackEntry is a stack generated by alf-cli, as recorded in the registry
+type StackEntry struct {
+	Name     string    `json:"name"`
+	Dir      string    `json:"dir"`
+	Version  string    `json:"version"`
+	Database string    `json:"database"`
+	Created  time.Time `json:"created"`
+}
+
+// RegistryPath returns the registry file under the user config directory
+// (e.g. ~/.config/alf-cli/stacks.json on Linux).
+func RegistryPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "alf-cli", "stacks.json"), nil
+}
+
+// LoadStacks returns the registered stacks; a missing registry is an empty one.
+func LoadStacks() ([]StackEntry, error) {
+	path, err := RegistryPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stacks []StackEntry
+	if err := json.Unmarshal(data, &stacks); err != nil {
+		return nil, err
+	}
+	return stacks, nil
+}
+
+// SaveStacks replaces the registry content.
+func SaveStacks(stacks []StackEntry) error {
+	path, err := RegistryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(stacks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// RegisterStack adds a stack to the registry, replacing any entry for the same folder.
+func RegisterStack(entry StackEntry) error {
+	stacks, err := LoadStacks()
+	if err != nil {
+		return err
+	}
+	stacks = slices.DeleteFunc(stacks, func(s StackEntry) bool { return s.Dir == entry.Dir })
+	return SaveStacks(append(stacks, entry))
+}
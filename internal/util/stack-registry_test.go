@@ -0,0 +1,39 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStackRegistry(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("HOME", config)
+
+	stacks, err := LoadStacks()
+	if err != nil || len(stacks) != 0 {
+		t.Fatalf("missing registry: got %v, %v, want no stacks", stacks, err)
+	}
+
+	first := StackEntry{Name: "alfresco", Dir: "/work/alfresco", Version: "25.2", Database: "postgres", Created: time.Unix(0, 0).UTC()}
+	again := first
+	again.Name, again.Database = "acs", "mariadb"
+	other := StackEntry{Name: "other", Dir: "/work/other", Version: "23.4", Database: "postgres", Created: time.Unix(0, 0).UTC()}
+	for _, entry := range []StackEntry{first, other, again} {
+		if err := RegisterStack(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stacks, err = LoadStacks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stacks) != 2 || stacks[0] != other || stacks[1] != again {
+		t.Errorf("got %+v, want %+v and %+v", stacks, other, again)
+	}
+	if path, err := RegistryPath(); err != nil || !strings.HasPrefix(path, config) {
+		t.Errorf("registry path %q (%v) outside %s", path, err, config)
+	}
+}
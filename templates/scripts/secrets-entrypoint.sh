@@ -0,0 +1,27 @@
+#!/bin/sh
+# Entrypoint added by alf-cli to the images reading Docker secrets (--docker-secrets).
+#
+# Compose bind mounts the files under secrets/ keeping their owner and 0600 mode, so
+# this script runs as root, reads them and then starts the image command as $RUN_AS:
+#
+#   SECRET_ENV="NAME=/run/secrets/x ..."         exports NAME with the content of the file
+#   SECRET_PROPERTIES="prop=/run/secrets/x ..."  appends -Dprop=<content> to the variable
+#                                                named by SECRET_OPTS (JAVA_OPTS by default)
+set -e
+
+for entry in $SECRET_ENV; do
+  value=$(cat "${entry#*=}")
+  export "${entry%%=*}=$value"
+done
+
+if [ -n "$SECRET_PROPERTIES" ]; then
+  target=${SECRET_OPTS:-JAVA_OPTS}
+  opts=$(printenv "$target" || true)
+  for entry in $SECRET_PROPERTIES; do
+    opts="$opts -D${entry%%=*}=$(cat "${entry#*=}")"
+  done
+  export "$target=$opts"
+fi
+unset SECRET_ENV SECRET_PROPERTIES SECRET_OPTS
+
+exec setpriv --reuid="$RUN_AS" --regid="$(id -g "$RUN_AS")" --init-groups "$@"
@@ -0,0 +1,150 @@
+package selector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ServiceStatus is a row of the status dashboard
+type ServiceStatus struct {
+	Service     string
+	State       string // Container state ("running", "exited", ...) or "" when not created
+	Health      string // "healthy", "starting", "unhealthy" or "" without healthcheck
+	CPU         float64
+	CPULimit    float64
+	MemMiB      float64
+	MemLimitMiB float64
+	Restarts    int
+}
+
+var (
+	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#fba100"))
+	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#ff5f5f"))
+	linkStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#017dff")).Underline(true)
+)
+
+// RenderStatusTable formats the service rows and proxy links shared by the
+// one-shot and the live status views.
+func RenderStatusTable(rows []ServiceStatus, links []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-24s %-10s %-10s %-18s %-20s %s\n", "SERVICE", "STATE", "HEALTH", "CPU", "MEMORY", "RESTARTS")
+	for _, r := range rows {
+		state := r.State
+		if state == "" {
+			state = "-"
+		}
+		health := r.Health
+		if health == "" {
+			health = "-"
+		}
+		cpu := fmt.Sprintf("%.2f / %.2f", r.CPU, r.CPULimit)
+		mem := fmt.Sprintf("%.0f / %.0f MiB", r.MemMiB, r.MemLimitMiB)
+
+		fmt.Fprintf(&b, "%-24s %s %s %s %s %d\n",
+			r.Service,
+			stateStyle(state).Render(fmt.Sprintf("%-10s", state)),
+			stateStyle(health).Render(fmt.Sprintf("%-10s", health)),
+			usageStyle(r.CPU, r.CPULimit).Render(fmt.Sprintf("%-18s", cpu)),
+			usageStyle(r.MemMiB, r.MemLimitMiB).Render(fmt.Sprintf("%-20s", mem)),
+			r.Restarts)
+	}
+	if len(links) > 0 {
+		b.WriteString("\n")
+		for _, link := range links {
+			// OSC 8 hyperlink, rendered as plain text by terminals without support
+			fmt.Fprintf(&b, "  \x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\\n", link, linkStyle.Render(link))
+		}
+	}
+	return b.String()
+}
+
+func stateStyle(state string) lipgloss.Style {
+	switch state {
+	case "running", "healthy":
+		return checkedStyle
+	case "starting", "created", "restarting", "-":
+		return warningStyle
+	default:
+		return errorStyle
+	}
+}
+
+func usageStyle(used, limit float64) lipgloss.Style {
+	switch {
+	case limit <= 0 || used < .8*limit:
+		return itemStyle
+	case used < limit:
+		return warningStyle
+	default:
+		return errorStyle
+	}
+}
+
+// RunStatusDashboard refreshes the status rows every interval until the user quits.
+func RunStatusDashboard(title string, links []string, interval time.Duration, refresh func() ([]ServiceStatus, error)) error {
+	m := dashboardModel{title: title, links: links, interval: interval, refresh: refresh}
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+type statusMsg struct {
+	rows []ServiceStatus
+	err  error
+	at   time.Time
+}
+
+type dashboardModel struct {
+	title    string
+	links    []string
+	interval time.Duration
+	refresh  func() ([]ServiceStatus, error)
+	rows     []ServiceStatus
+	err      error
+	updated  time.Time
+}
+
+func (m dashboardModel) fetch() tea.Cmd {
+	return func() tea.Msg {
+		rows, err := m.refresh()
+		return statusMsg{rows: rows, err: err, at: time.Now()}
+	}
+}
+
+func (m dashboardModel) Init() tea.Cmd { return m.fetch() }
+
+func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			return m, tea.Quit
+		}
+	case statusMsg:
+		m.rows, m.err, m.updated = msg.rows, msg.err, msg.at
+		return m, tea.Tick(m.interval, func(time.Time) tea.Msg { return m.fetch()() })
+	}
+	return m, nil
+}
+
+func (m dashboardModel) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(m.title) + "\n\n")
+	switch {
+	case m.err != nil:
+		b.WriteString(errorStyle.Render(m.err.Error()) + "\n")
+	case m.updated.IsZero():
+		b.WriteString("Loading...\n")
+	default:
+		b.WriteString(RenderStatusTable(m.rows, m.links))
+	}
+	updated := "-"
+	if !m.updated.IsZero() {
+		updated = m.updated.Format("15:04:05")
+	}
+	b.WriteString("\n" + helpStyle.Render(fmt.Sprintf("updated %s • q quit", updated)) + "\n")
+	return b.String()
+}